@@ -0,0 +1,128 @@
+package statediff
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-ipfs-blockstore"
+	"github.com/willscott/go-cmp/cmp"
+)
+
+// Change is a single modified value within an actor.
+type Change struct {
+	Path   string
+	Before interface{}
+	After  interface{}
+}
+
+// ChangeSet is the difference between two state roots, organized by actor.
+// Actors are keyed by address, or by name for the singleton actors.
+type ChangeSet struct {
+	// Added holds actors present only in the later state root.
+	Added map[string]interface{}
+	// Removed holds actors present only in the earlier state root.
+	Removed map[string]interface{}
+	// Modified lists the changed fields of actors present in both.
+	Modified map[string][]Change
+}
+
+// Marshal renders the change set as indented JSON.
+func (cs *ChangeSet) Marshal(opts ...JSONOption) (string, error) {
+	return PrettyJSON(cs, opts...)
+}
+
+// DiffChanges compares stateroots `a` and `b`, as Diff does, but returns the
+// differences as a structured ChangeSet rather than as text.
+func DiffChanges(ctx context.Context, store blockstore.Blockstore, a, b cid.Cid, opts ...Option) *ChangeSet {
+	conf := config{}
+	for _, o := range opts {
+		o(&conf)
+	}
+
+	r := changeReporter{set: &ChangeSet{
+		Added:    make(map[string]interface{}),
+		Removed:  make(map[string]interface{}),
+		Modified: make(map[string][]Change),
+	}}
+	cmpOpts := diffOptions(ctx, store, a, &conf)
+	cmp.Equal(a, b, append(cmpOpts, cmp.Reporter(&r))...)
+	return r.set
+}
+
+// changeReporter collects the leaf differences found by cmp into a ChangeSet.
+type changeReporter struct {
+	path cmp.Path
+	set  *ChangeSet
+}
+
+func (r *changeReporter) PushStep(ps cmp.PathStep) {
+	r.path = append(r.path, ps)
+}
+
+func (r *changeReporter) PopStep() {
+	r.path = r.path[:len(r.path)-1]
+}
+
+func (r *changeReporter) Report(rs cmp.Result) {
+	if rs.Equal() {
+		return
+	}
+
+	// The first string keyed map entry along the path is the actor, within
+	// the state tree expanded from the root.
+	actor := -1
+	for i, ps := range r.path {
+		if mi, ok := ps.(cmp.MapIndex); ok && mi.Key().Kind() == reflect.String {
+			actor = i
+			break
+		}
+	}
+	before, after := r.path.Last().Values()
+	if actor < 0 {
+		r.set.Modified[""] = append(r.set.Modified[""], Change{r.path.String(), valueOf(before), valueOf(after)})
+		return
+	}
+
+	name := r.path[actor].(cmp.MapIndex).Key().String()
+	if actor == len(r.path)-1 {
+		switch {
+		case !before.IsValid():
+			r.set.Added[name] = valueOf(after)
+			return
+		case !after.IsValid():
+			r.set.Removed[name] = valueOf(before)
+			return
+		}
+	}
+	r.set.Modified[name] = append(r.set.Modified[name], Change{fieldPath(r.path[actor+1:]), valueOf(before), valueOf(after)})
+}
+
+// fieldPath renders the steps within an actor as a readable path.
+func fieldPath(steps cmp.Path) string {
+	parts := make([]string, 0, len(steps))
+	for _, ps := range steps {
+		switch s := ps.(type) {
+		case cmp.StructField:
+			parts = append(parts, s.Name())
+		case cmp.MapIndex:
+			parts = append(parts, fmt.Sprintf("%v", s.Key()))
+		case cmp.SliceIndex:
+			parts = append(parts, fmt.Sprintf("%d", s.Key()))
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// valueOf extracts a compared value for output, or nil when it is absent.
+func valueOf(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if !v.CanInterface() {
+		return fmt.Sprintf("%v", v)
+	}
+	return v.Interface()
+}
@@ -33,6 +33,12 @@ var bindFlag = cli.StringFlag{
 	Value: ":0",
 }
 
+var prefetchFlag = cli.IntFlag{
+	Name:  "prefetch",
+	Usage: "Number of concurrent block fetches used to warm the cache before walking large collections. 0 disables prefetching",
+	Value: 0,
+}
+
 var exploreCmd = &cli.Command{
 	Name:        "explore",
 	Description: "Examine a state tree in a browser",
@@ -42,6 +48,7 @@ var exploreCmd = &cli.Command{
 		&lib.CarFlag,
 		&assetsFlag,
 		&bindFlag,
+		&prefetchFlag,
 	},
 }
 
@@ -51,6 +58,11 @@ func runExploreCmd(c *cli.Context) error {
 		return err
 	}
 
+	var opts []statediff.TransformOption
+	if workers := c.Int(prefetchFlag.Name); workers > 0 {
+		opts = append(opts, statediff.Prefetch(workers))
+	}
+
 	cidResolver := func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Cache-Control", "public, max-age=604800, immutable")
@@ -76,7 +88,7 @@ func runExploreCmd(c *cli.Context) error {
 			return
 		}
 
-		transformed, err := statediff.Transform(r.Context(), parsed, store, as[0])
+		transformed, err := statediff.Transform(r.Context(), parsed, store, as[0], opts...)
 		if err != nil {
 			w.Header().Set("Content-Type", "text/plain")
 			w.Write([]byte(fmt.Sprintf("error: %s", err)))
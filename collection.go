@@ -0,0 +1,312 @@
+package statediff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	addr "github.com/filecoin-project/go-address"
+	amt "github.com/filecoin-project/go-amt-ipld/v2"
+	abi "github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-cid"
+	hamt "github.com/ipfs/go-hamt-ipld"
+	"github.com/ipfs/go-ipfs-blockstore"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+// Page bounds the entries decoded by TransformAMT and TransformHAMT, so that
+// a large collection can be transformed in chunks.
+type Page struct {
+	// Cursor is the key of the first entry of the page, taken from the Next
+	// of the previous page. It is empty to start at the beginning of the
+	// collection.
+	Cursor string
+	// Limit is the maximum number of entries to decode, or 0 for no limit.
+	//
+	// An AMT page starts directly at its cursor. A HAMT is walked in the
+	// order of its hashed keys and cannot be sought by key, so each HAMT page
+	// walks every entry before its cursor, and reading a whole HAMT page by
+	// page takes time quadratic in its size.
+	Limit int
+	// Next is set after a transform to the key of the first entry of the
+	// following page, or left empty once the end of the collection has been
+	// reached.
+	Next string
+}
+
+// Paginate limits a collection transform to the page described by `p`, and
+// reports the cursor of the next page in it.
+func Paginate(p *Page) TransformOption {
+	return func(c *transformConfig) {
+		p.Next = ""
+		c.Page = p
+	}
+}
+
+// ErrUnknownCursor is returned when the cursor of a page is not the key of an
+// entry of the HAMT being paginated.
+var ErrUnknownCursor = errors.New("unknown cursor")
+
+// errPageFull stops the walk of a collection once a page has been filled.
+var errPageFull = errors.New("page full")
+
+// full indicates if a page already holds `n` entries, its limit.
+func (p *Page) full(n int) bool {
+	return p != nil && p.Limit > 0 && n >= p.Limit
+}
+
+// VerifyRoot rebuilds the collection read by TransformAMT or TransformHAMT
+// from the entries found, and checks that it hashes to the cid it was read
+// from. A mismatch, reported as a *CIDMismatchError, means entries were
+// missed, such as when a HAMT is read with the wrong bit width. The whole
+// collection must be read, so it cannot be combined with Paginate.
+func VerifyRoot(c *transformConfig) {
+	c.VerifyRoot = true
+}
+
+// errVerifyPage is returned when verifying a root is requested for a page.
+var errVerifyPage = errors.New("cannot verify the root of a paginated collection")
+
+// enterCollection applies the options of a TransformAMT or TransformHAMT
+// config which do not depend on its elements, rejecting those which cannot
+// apply to a collection. The collection is the first level counted by
+// MaxDepth, and as its elements are held within a single block it never
+// nests deeper. A successful enter must be paired with a leave.
+func (c *transformConfig) enterCollection() error {
+	if c.VerifyRoot && c.Page != nil {
+		return errVerifyPage
+	}
+	if c.ActorRoot.Defined() {
+		return fmt.Errorf("%w: cannot check the actor code of a collection", ErrNotActorState)
+	}
+	return c.enter()
+}
+
+// checkRoot compares the root of a rebuilt collection with the original.
+func checkRoot(expected, computed cid.Cid) error {
+	if !computed.Equals(expected) {
+		return &CIDMismatchError{Expected: expected, Computed: computed}
+	}
+	return nil
+}
+
+// TransformAMT decodes an arbitrary AMT, given its root cid and the LotusType
+// of its elements, into a map from index to element. Elements must be of a
+// type held within a single block, such as `storageMinerActor.Deadlines.Due`.
+// With Validate, each element is checked as TransformType checks a value, and
+// the first violation is returned alongside the decoded elements.
+// CheckActorCode does not apply to a collection and is rejected.
+func TransformAMT(ctx context.Context, c cid.Cid, store blockstore.Blockstore, elemType LotusType, opts ...TransformOption) (map[int64]interface{}, error) {
+	conf := transformConfig{}
+	for _, o := range opts {
+		o(&conf)
+	}
+	t, ok := lookupType(elemType)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a decodable element type", elemType)
+	}
+	if err := conf.enterCollection(); err != nil {
+		return nil, err
+	}
+	defer conf.leave()
+
+	store = conf.wrapStore(store)
+	cborStore := cbor.NewCborStore(store)
+	if conf.Prefetch > 0 {
+		prefetchAMT(ctx, cborStore, c, conf.Prefetch)
+	}
+	root, err := amt.LoadAMT(ctx, cborStore, c)
+	if err != nil {
+		return nil, err
+	}
+
+	var start uint64
+	if conf.Page != nil && conf.Page.Cursor != "" {
+		if start, err = strconv.ParseUint(conf.Page.Cursor, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid AMT cursor %q: %w", conf.Page.Cursor, err)
+		}
+	}
+	var rebuilt *amt.Root
+	if conf.VerifyRoot {
+		rebuilt = amt.NewAMT(cbor.NewMemCborStore())
+	}
+	m := make(map[int64]interface{})
+	var invalid error
+	if err := root.ForEachAt(ctx, start, func(k uint64, value *cbg.Deferred) error {
+		if conf.Page.full(len(m)) {
+			conf.Page.Next = strconv.FormatUint(k, 10)
+			return errPageFull
+		}
+		elem, err := conf.decode(value.Raw, t)
+		if err != nil {
+			return fmt.Errorf("element %d: %w", k, err)
+		}
+		if conf.Validate && invalid == nil {
+			if err := validate(ctx, elem, store); err != nil {
+				invalid = fmt.Errorf("element %d: %w", k, err)
+			}
+		}
+		m[int64(k)] = elem
+		if rebuilt != nil {
+			return rebuilt.Set(ctx, k, value)
+		}
+		return nil
+	}); err != nil && err != errPageFull {
+		return nil, err
+	}
+	if rebuilt != nil {
+		computed, err := rebuilt.Flush(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkRoot(c, computed); err != nil {
+			return nil, err
+		}
+	}
+	conf.countEntries(m)
+	return m, invalid
+}
+
+// KeyKind describes how the keys of a HAMT are encoded.
+type KeyKind int
+
+const (
+	// KeyString keys are used as they are.
+	KeyString KeyKind = iota
+	// KeyInt keys are varint encoded signed integers.
+	KeyInt
+	// KeyUint keys are varint encoded unsigned integers.
+	KeyUint
+	// KeyAddress keys are the binary form of an address.
+	KeyAddress
+	// KeyCid keys are the binary form of a cid.
+	KeyCid
+)
+
+// decodeKey renders a raw HAMT key of the given kind as a string.
+func decodeKey(k string, kind KeyKind) (string, error) {
+	switch kind {
+	case KeyString:
+		return k, nil
+	case KeyInt:
+		i, err := abi.ParseIntKey(k)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatInt(i, 10), nil
+	case KeyUint:
+		u, err := abi.ParseUIntKey(k)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatUint(u, 10), nil
+	case KeyAddress:
+		a, err := addr.NewFromBytes([]byte(k))
+		if err != nil {
+			return "", err
+		}
+		return a.String(), nil
+	case KeyCid:
+		c, err := cid.Cast([]byte(k))
+		if err != nil {
+			return "", err
+		}
+		return c.String(), nil
+	default:
+		return "", fmt.Errorf("unknown key kind %d", kind)
+	}
+}
+
+// TransformHAMT decodes an arbitrary HAMT, given its root cid, the encoding
+// of its keys, the LotusType of its values and the bit width it was built
+// with, into a map from rendered key to value. Values must be of a type held
+// within a single block. The actors use a bit width of 5. Options are applied
+// as by TransformAMT.
+func TransformHAMT(ctx context.Context, c cid.Cid, store blockstore.Blockstore, keyKind KeyKind, valType LotusType, bitwidth int, opts ...TransformOption) (map[string]interface{}, error) {
+	conf := transformConfig{}
+	for _, o := range opts {
+		o(&conf)
+	}
+	t, ok := lookupType(valType)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a decodable value type", valType)
+	}
+	if err := conf.enterCollection(); err != nil {
+		return nil, err
+	}
+	defer conf.leave()
+
+	store = conf.wrapStore(store)
+	cborStore := cbor.NewCborStore(store)
+	node, err := hamt.LoadNode(ctx, cborStore, c, hamt.UseTreeBitWidth(bitwidth))
+	if err != nil {
+		return nil, err
+	}
+	if conf.Prefetch > 0 {
+		prefetchHAMT(ctx, cborStore, node, conf.Prefetch, hamt.UseTreeBitWidth(bitwidth))
+	}
+
+	var rebuilt *hamt.Node
+	rebuiltStore := cbor.NewMemCborStore()
+	if conf.VerifyRoot {
+		rebuilt = hamt.NewNode(rebuiltStore, hamtOptions(bitwidth)...)
+	}
+	m := make(map[string]interface{})
+	var invalid error
+	// HAMTs are walked in the order of their hashed keys, which is stable
+	// but cannot be sought, so resuming skips the entries before the cursor.
+	skipping := conf.Page != nil && conf.Page.Cursor != ""
+	if err := node.ForEach(ctx, func(k string, val interface{}) error {
+		asDef, ok := val.(*cbg.Deferred)
+		if !ok {
+			return fmt.Errorf("unexpected non-cbg.Deferred")
+		}
+		key, err := decodeKey(k, keyKind)
+		if err != nil {
+			return fmt.Errorf("invalid key %x: %w", k, err)
+		}
+		if skipping && key != conf.Page.Cursor {
+			return nil
+		}
+		skipping = false
+		if conf.Page.full(len(m)) {
+			conf.Page.Next = key
+			return errPageFull
+		}
+		value, err := conf.decode(asDef.Raw, t)
+		if err != nil {
+			return fmt.Errorf("value at %s: %w", key, err)
+		}
+		if conf.Validate && invalid == nil {
+			if err := validate(ctx, value, store); err != nil {
+				invalid = fmt.Errorf("value at %s: %w", key, err)
+			}
+		}
+		m[key] = value
+		if rebuilt != nil {
+			return rebuilt.SetRaw(ctx, k, asDef.Raw)
+		}
+		return nil
+	}); err != nil && err != errPageFull {
+		return nil, err
+	}
+	if skipping {
+		return nil, fmt.Errorf("%w %q", ErrUnknownCursor, conf.Page.Cursor)
+	}
+	if rebuilt != nil {
+		if err := rebuilt.Flush(ctx); err != nil {
+			return nil, err
+		}
+		computed, err := rebuiltStore.Put(ctx, rebuilt)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkRoot(c, computed); err != nil {
+			return nil, err
+		}
+	}
+	conf.countEntries(m)
+	return m, invalid
+}
@@ -0,0 +1,258 @@
+package statediff_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+
+	abi "github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-ipfs-blockstore"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	accountActor "github.com/filecoin-project/specs-actors/actors/builtin/account"
+	initActor "github.com/filecoin-project/specs-actors/actors/builtin/init"
+
+	"github.com/filecoin-project/statediff"
+	"github.com/filecoin-project/statediff/testutil"
+)
+
+var pageIndexes = []uint64{0, 3, 4, 7, 20, 21, 22, 64, 65, 1000}
+
+// paginate reads every page of a collection, failing if an entry is read
+// twice or a page does not start at its cursor.
+func paginate(t *testing.T, limit int, read func(p *statediff.Page) ([]string, error)) map[string]bool {
+	t.Helper()
+	seen := make(map[string]bool)
+	p := &statediff.Page{Limit: limit}
+	for pages := 0; ; pages++ {
+		if pages > len(pageIndexes) {
+			t.Fatal("pagination does not terminate")
+		}
+		keys, err := read(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) > limit {
+			t.Fatalf("page of %d entries, limit %d", len(keys), limit)
+		}
+		cursorFound := p.Cursor == ""
+		for _, k := range keys {
+			if seen[k] {
+				t.Fatalf("entry %s read on two pages", k)
+			}
+			seen[k] = true
+			cursorFound = cursorFound || k == p.Cursor
+		}
+		if !cursorFound {
+			t.Fatalf("page at cursor %s does not hold it: %v", p.Cursor, keys)
+		}
+		if p.Next == "" {
+			return seen
+		}
+		p.Cursor = p.Next
+	}
+}
+
+func checkAllPaged(t *testing.T, seen map[string]bool) {
+	t.Helper()
+	if len(seen) != len(pageIndexes) {
+		t.Errorf("read %d entries, want %d", len(seen), len(pageIndexes))
+	}
+	for _, i := range pageIndexes {
+		if !seen[strconv.FormatUint(i, 10)] {
+			t.Errorf("entry %d never read", i)
+		}
+	}
+}
+
+func TestTransformAMTPagination(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+	entries := make(map[uint64]cbg.CBORMarshaler)
+	for _, i := range pageIndexes {
+		entries[i] = &accountActor.State{Address: idAddr(t, i)}
+	}
+	root := mustAMT(t, store, entries)
+
+	for _, limit := range []int{1, 3, len(pageIndexes), len(pageIndexes) + 1} {
+		t.Run(strconv.Itoa(limit), func(t *testing.T) {
+			checkAllPaged(t, paginate(t, limit, func(p *statediff.Page) ([]string, error) {
+				m, err := statediff.TransformAMT(ctx, root, store, statediff.AccountActorState, statediff.Paginate(p))
+				keys := make([]string, 0, len(m))
+				for k := range m {
+					keys = append(keys, strconv.FormatInt(k, 10))
+				}
+				return keys, err
+			}))
+		})
+	}
+}
+
+func pageHAMT(t *testing.T, store blockstore.Blockstore) cid.Cid {
+	t.Helper()
+	entries := make(map[string]cbg.CBORMarshaler)
+	for _, i := range pageIndexes {
+		entries[abi.UIntKey(i).Key()] = &accountActor.State{Address: idAddr(t, i)}
+	}
+	return mustHAMT(t, store, entries, 5)
+}
+
+func TestTransformHAMTPagination(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+	root := pageHAMT(t, store)
+
+	for _, limit := range []int{1, 3, len(pageIndexes), len(pageIndexes) + 1} {
+		t.Run(strconv.Itoa(limit), func(t *testing.T) {
+			checkAllPaged(t, paginate(t, limit, func(p *statediff.Page) ([]string, error) {
+				m, err := statediff.TransformHAMT(ctx, root, store, statediff.KeyUint, statediff.AccountActorState, 5, statediff.Paginate(p))
+				keys := make([]string, 0, len(m))
+				for k := range m {
+					keys = append(keys, k)
+				}
+				return keys, err
+			}))
+		})
+	}
+}
+
+func TestTransformHAMTUnknownCursor(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+	root := pageHAMT(t, store)
+
+	p := &statediff.Page{Cursor: "5", Limit: 3}
+	_, err := statediff.TransformHAMT(ctx, root, store, statediff.KeyUint, statediff.AccountActorState, 5, statediff.Paginate(p))
+	if !errors.Is(err, statediff.ErrUnknownCursor) {
+		t.Errorf("expected ErrUnknownCursor, got %v", err)
+	}
+}
+
+func TestVerifyRoot(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+	hamtRoot := pageHAMT(t, store)
+	entries := make(map[uint64]cbg.CBORMarshaler)
+	for _, i := range pageIndexes {
+		entries[i] = &accountActor.State{Address: idAddr(t, i)}
+	}
+	amtRoot := mustAMT(t, store, entries)
+
+	if _, err := statediff.TransformAMT(ctx, amtRoot, store, statediff.AccountActorState, statediff.VerifyRoot); err != nil {
+		t.Errorf("verifying AMT: %v", err)
+	}
+	if _, err := statediff.TransformHAMT(ctx, hamtRoot, store, statediff.KeyUint, statediff.AccountActorState, 5, statediff.VerifyRoot); err != nil {
+		t.Errorf("verifying HAMT: %v", err)
+	}
+
+	var mismatch *statediff.CIDMismatchError
+	_, err := statediff.TransformHAMT(ctx, hamtRoot, store, statediff.KeyUint, statediff.AccountActorState, 3, statediff.VerifyRoot)
+	if !errors.As(err, &mismatch) {
+		t.Errorf("HAMT read with the wrong bit width verified with %v", err)
+	}
+
+	p := &statediff.Page{Limit: 1}
+	if _, err := statediff.TransformAMT(ctx, amtRoot, store, statediff.AccountActorState, statediff.VerifyRoot, statediff.Paginate(p)); err == nil {
+		t.Error("verified the root of a single page")
+	}
+}
+
+func TestTransformCollectionOptions(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+	hamtRoot := pageHAMT(t, store)
+
+	id := cbg.CborInt(5)
+	addressMap := mustHAMT(t, store, map[string]cbg.CBORMarshaler{
+		string(idAddr(t, 1000).Bytes()): &id,
+	}, 5)
+	amtRoot := mustAMT(t, store, map[uint64]cbg.CBORMarshaler{
+		0: &initActor.State{AddressMap: addressMap, NextID: 10, NetworkName: "test"},
+		1: &initActor.State{AddressMap: addressMap, NextID: 5, NetworkName: "test"},
+	})
+
+	m, err := statediff.TransformAMT(ctx, amtRoot, store, statediff.InitActorState, statediff.Validate, statediff.MaxDepth(1))
+	var invalid *statediff.InitActorValidationError
+	if !errors.As(err, &invalid) || invalid.NextID != 5 {
+		t.Errorf("expected the second element to fail validation, got %v", err)
+	}
+	if len(m) != 2 {
+		t.Errorf("expected both elements alongside the violation, got %v", m)
+	}
+
+	check := statediff.CheckActorCode(hamtRoot, idAddr(t, 1000))
+	if _, err := statediff.TransformAMT(ctx, amtRoot, store, statediff.InitActorState, check); !errors.Is(err, statediff.ErrNotActorState) {
+		t.Errorf("expected ErrNotActorState checking the code of an AMT, got %v", err)
+	}
+	if _, err := statediff.TransformHAMT(ctx, hamtRoot, store, statediff.KeyUint, statediff.AccountActorState, 5, check); !errors.Is(err, statediff.ErrNotActorState) {
+		t.Errorf("expected ErrNotActorState checking the code of a HAMT, got %v", err)
+	}
+}
+
+func TestTransformAMT(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+	root := mustAMT(t, store, map[uint64]cbg.CBORMarshaler{
+		2:   &accountActor.State{Address: idAddr(t, 100)},
+		700: &accountActor.State{Address: idAddr(t, 101)},
+	})
+
+	m, err := statediff.TransformAMT(ctx, root, store, statediff.AccountActorState)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int64]interface{}{
+		2:   accountActor.State{Address: idAddr(t, 100)},
+		700: accountActor.State{Address: idAddr(t, 101)},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("transformed as %v, want %v", m, want)
+	}
+
+	if _, err := statediff.TransformAMT(ctx, root, store, statediff.MarketActorProposals); err == nil {
+		t.Error("transformed an AMT with a collection as its element type")
+	}
+	if _, err := statediff.TransformAMT(ctx, root, store, statediff.StorageMinerActorInfo); err == nil {
+		t.Error("transformed account states as miner info")
+	}
+}
+
+func TestTransformHAMTKeyKinds(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+	value := &accountActor.State{Address: idAddr(t, 100)}
+	keyCid := mustPut(t, store, value)
+
+	for _, tc := range []struct {
+		name string
+		kind statediff.KeyKind
+		raw  string
+		want string
+	}{
+		{"string", statediff.KeyString, "name", "name"},
+		{"int", statediff.KeyInt, abi.IntKey(-7).Key(), "-7"},
+		{"uint", statediff.KeyUint, abi.UIntKey(1 << 40).Key(), "1099511627776"},
+		{"address", statediff.KeyAddress, abi.AddrKey(idAddr(t, 1000)).Key(), "t01000"},
+		{"cid", statediff.KeyCid, abi.CidKey(keyCid).Key(), keyCid.String()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			root := mustHAMT(t, store, map[string]cbg.CBORMarshaler{tc.raw: value}, 5)
+			m, err := statediff.TransformHAMT(ctx, root, store, tc.kind, statediff.AccountActorState, 5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := map[string]interface{}{tc.want: *value}
+			if !reflect.DeepEqual(m, want) {
+				t.Errorf("transformed as %v, want %v", m, want)
+			}
+		})
+	}
+
+	root := mustHAMT(t, store, map[string]cbg.CBORMarshaler{"name": value}, 5)
+	if _, err := statediff.TransformHAMT(ctx, root, store, statediff.KeyAddress, statediff.AccountActorState, 5); err == nil {
+		t.Error("rendered an invalid address key")
+	}
+}
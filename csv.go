@@ -0,0 +1,114 @@
+package statediff
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// MarshalCSV renders a transformed collection, such as a miner's sectors or
+// the market's deal proposals, as a table. The first column holds the key of
+// each entry and the remaining columns the fields of its value, which must be
+// structs of a single type. Cells are rendered as by MarshalJSON with the same
+// options, with strings left unquoted. `comma` separates fields, so '\t'
+// produces TSV.
+func MarshalCSV(v interface{}, comma rune, opts ...JSONOption) ([]byte, error) {
+	conf := jsonConfig{}
+	for _, o := range opts {
+		o(&conf)
+	}
+
+	m := reflect.ValueOf(v)
+	if m.Kind() != reflect.Map {
+		return nil, fmt.Errorf("cannot render %T as a table", v)
+	}
+
+	type row struct {
+		key   reflect.Value
+		value reflect.Value
+	}
+	rows := make([]row, 0, m.Len())
+	iter := m.MapRange()
+	for iter.Next() {
+		rows = append(rows, row{iter.Key(), indirect(iter.Value())})
+	}
+	sort.Slice(rows, func(i, j int) bool { return keyLess(rows[i].key, rows[j].key) })
+
+	var out bytes.Buffer
+	w := csv.NewWriter(&out)
+	w.Comma = comma
+	var rowType reflect.Type
+	for _, r := range rows {
+		if r.value.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("cannot render %s as a table row", r.value.Type())
+		}
+		if rowType == nil {
+			rowType = r.value.Type()
+			header := []string{"Key"}
+			forEachJSONField(r.value, func(name string, _ bool, _ reflect.Value) error {
+				header = append(header, name)
+				return nil
+			})
+			if err := w.Write(header); err != nil {
+				return nil, err
+			}
+		} else if r.value.Type() != rowType {
+			return nil, fmt.Errorf("mixed row types %s and %s", rowType, r.value.Type())
+		}
+
+		key, err := mapKeyString(r.key)
+		if err != nil {
+			return nil, err
+		}
+		record := []string{key}
+		if err := forEachJSONField(r.value, func(_ string, _ bool, field reflect.Value) error {
+			cell, err := csvCell(field, &conf)
+			record = append(record, cell)
+			return err
+		}); err != nil {
+			return nil, err
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return out.Bytes(), w.Error()
+}
+
+// csvCell renders a single value as JSON, unquoting it if it is a string.
+func csvCell(v reflect.Value, conf *jsonConfig) (string, error) {
+	e := jsonEncoder{conf: conf}
+	if err := e.encode(v); err != nil {
+		return "", err
+	}
+	var s string
+	if err := json.Unmarshal(e.Bytes(), &s); err == nil {
+		return s, nil
+	}
+	return e.String(), nil
+}
+
+// indirect follows pointers and interfaces to the value they hold.
+func indirect(v reflect.Value) reflect.Value {
+	for (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+// keyLess orders map keys, numerically when they are integers.
+func keyLess(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() < b.Uint()
+	}
+	as, _ := mapKeyString(a)
+	bs, _ := mapKeyString(b)
+	return as < bs
+}
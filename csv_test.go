@@ -0,0 +1,53 @@
+package statediff_test
+
+import (
+	"testing"
+
+	abi "github.com/filecoin-project/go-state-types/abi"
+	marketActor "github.com/filecoin-project/specs-actors/actors/builtin/market"
+
+	"github.com/filecoin-project/statediff"
+)
+
+func TestMarshalCSV(t *testing.T) {
+	type sector struct {
+		Size  abi.SectorSize
+		Label string
+	}
+	sectors := map[int64]*sector{
+		10: {2048, "b,c"},
+		9:  {34359738368, "a"},
+	}
+
+	for _, tc := range []struct {
+		name  string
+		comma rune
+		opts  []statediff.JSONOption
+		want  string
+	}{
+		{"csv", ',', nil, "Key,Size,Label\n9,34359738368,a\n10,2048,\"b,c\"\n"},
+		{"tsv", '\t', nil, "Key\tSize\tLabel\n9\t34359738368\ta\n10\t2048\tb,c\n"},
+		{"options", ',', []statediff.JSONOption{statediff.HumanSectorSizes}, "Key,Size,Label\n9,32GiB,a\n10,2KiB,\"b,c\"\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := statediff.MarshalCSV(sectors, tc.comma, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tc.want {
+				t.Errorf("rendered as %q, want %q", out, tc.want)
+			}
+		})
+	}
+
+	if _, err := statediff.MarshalCSV([]uint64{1}, ','); err == nil {
+		t.Error("rendered a list as a table")
+	}
+	mixed := map[int64]interface{}{0: sector{}, 1: *testProposal(t, 100, 1000, 10)}
+	if _, err := statediff.MarshalCSV(mixed, ','); err == nil {
+		t.Error("rendered rows of mixed types")
+	}
+	if _, err := statediff.MarshalCSV(map[int64]marketActor.DealProposal{}, ','); err != nil {
+		t.Errorf("rendering an empty collection: %v", err)
+	}
+}
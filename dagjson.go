@@ -0,0 +1,84 @@
+package statediff
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-ipfs-blockstore"
+	cbor "github.com/ipfs/go-ipld-cbor"
+)
+
+// MarshalDagJSON writes the block at `c` as dag-json, without interpreting it
+// as any LotusType. Structs appear as the lists and maps they are encoded as,
+// links as `{"/": cid}` and bytes as `{"/": {"bytes": base64}}`, making the
+// output suitable for consumption by other IPLD tools.
+func MarshalDagJSON(c cid.Cid, store blockstore.Blockstore, w io.Writer) error {
+	block, err := (&classifyingBlockstore{store}).Get(c)
+	if err != nil {
+		return err
+	}
+	var obj interface{}
+	if err := cbor.DecodeInto(block.RawData(), &obj); err != nil {
+		return err
+	}
+	dag, err := toDagJSON(obj)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(dag)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// toDagJSON converts generically decoded cbor into values which encoding/json
+// renders as dag-json.
+func toDagJSON(obj interface{}) (interface{}, error) {
+	switch v := obj.(type) {
+	case cid.Cid:
+		return map[string]string{"/": v.String()}, nil
+	case []byte:
+		return map[string]map[string]string{"/": {"bytes": base64.RawStdEncoding.EncodeToString(v)}}, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			conv, err := toDagJSON(e)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = conv
+		}
+		return out, nil
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			conv, err := toDagJSON(e)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = conv
+		}
+		return out, nil
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("non-string map key %v", k)
+			}
+			conv, err := toDagJSON(e)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = conv
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
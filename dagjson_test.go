@@ -0,0 +1,47 @@
+package statediff_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	accountActor "github.com/filecoin-project/specs-actors/actors/builtin/account"
+	"github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	"github.com/filecoin-project/statediff"
+	"github.com/filecoin-project/statediff/testutil"
+)
+
+func TestMarshalDagJSON(t *testing.T) {
+	store := testutil.NewMemStore()
+	account := mustPut(t, store, &accountActor.State{Address: idAddr(t, 100)})
+	link := cbg.CborCid(account)
+	linked := mustPut(t, store, &link)
+
+	for _, tc := range []struct {
+		name string
+		c    cid.Cid
+		want string
+	}{
+		// The ID address 100 is the bytes 0x00 0x64.
+		{"bytes", account, `[{"/":{"bytes":"AGQ"}}]`},
+		{"link", linked, `{"/":"` + account.String() + `"}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := statediff.MarshalDagJSON(tc.c, store, &out); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tc.want {
+				t.Errorf("rendered as %s, want %s", out.String(), tc.want)
+			}
+		})
+	}
+
+	missing := mustPut(t, testutil.NewMemStore(), &accountActor.State{Address: idAddr(t, 101)})
+	var notFound *statediff.BlockNotFoundError
+	if err := statediff.MarshalDagJSON(missing, store, &bytes.Buffer{}); !errors.As(err, &notFound) {
+		t.Errorf("expected a BlockNotFoundError, got %v", err)
+	}
+}
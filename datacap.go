@@ -0,0 +1,15 @@
+package statediff
+
+import (
+	verifiedRegistryActor "github.com/filecoin-project/specs-actors/actors/builtin/verifreg"
+)
+
+// JSONDataCap is a verified registry datacap. It renders as a decimal count
+// of bytes, or with binary units when marshaled with HumanDataCaps.
+type JSONDataCap struct {
+	verifiedRegistryActor.DataCap
+}
+
+func (d JSONDataCap) MarshalJSON() ([]byte, error) {
+	return d.DataCap.MarshalJSON()
+}
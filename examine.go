@@ -101,25 +101,20 @@ func Diff(ctx context.Context, store blockstore.Blockstore, a, b cid.Cid, opts .
 		o(&conf)
 	}
 
+	cmpOpts := diffOptions(ctx, store, a, &conf)
+	coreDiff := cmp.Diff(a, b, cmpOpts...)
+
+	header := fmt.Sprintf("--- %s\n+++ %s\n@@ -1,1 +1,1 @@\n", a, b)
+	return header + coreDiff
+}
+
+// diffOptions configures the comparison of state roots, expanding the state
+// reachable from the roots and naming actors by the init actor of root `a`.
+func diffOptions(ctx context.Context, store blockstore.Blockstore, a cid.Cid, conf *config) []cmp.Option {
 	cborStore := cbor.NewCborStore(store)
 	adtStore := adt.WrapStore(ctx, cborStore)
 
-	initActorTransformer := func(act initActor.State) *initActorState {
-		am, _ := adt.AsMap(adtStore, act.AddressMap)
-		var val cbg.CborInt
-		m := make(map[string]uint64)
-		am.ForEach(&val, func(k string) error {
-			address, _ := addr.NewFromBytes([]byte(k))
-			m[address.String()] = uint64(val)
-			return nil
-		})
-		return &initActorState{
-			NextID:      act.NextID.String(),
-			NetworkName: act.NetworkName,
-			ADTRoot:     act.AddressMap.String(),
-			ADT:         m,
-		}
-	}
+	initActorTransformer := newInitActorTransformer(adtStore)
 
 	stateTreeNamer := getInitFor(ctx, cborStore, a, initActorTransformer)
 
@@ -274,10 +269,138 @@ func Diff(ctx context.Context, store blockstore.Blockstore, a, b cid.Cid, opts .
 		cidMap[`\.Head$`] = reflect.TypeOf("")
 	}
 	cmpOpts = append(cmpOpts, cidTransformer(ctx, store, cborStore, cidMap)...)
-	coreDiff := cmp.Diff(a, b, cmpOpts...)
+	return cmpOpts
+}
 
-	header := fmt.Sprintf("--- %s\n+++ %s\n@@ -1,1 +1,1 @@\n", a, b)
-	return header + coreDiff
+// RangeDiff is the change between two consecutive state roots of a range.
+// If the step could not be diffed, Err is set and the range ends.
+type RangeDiff struct {
+	Pre     cid.Cid
+	Post    cid.Cid
+	Changes *ChangeSet
+	Err     error
+}
+
+// DiffRange compares each consecutive pair of an ordered list of state roots,
+// emitting the changes of each step on the returned channel. Each root is
+// loaded once, and only actors whose head differs between a pair of roots have
+// their state compared. The channel is closed once the range has been
+// exhausted, a step fails, or `ctx` is cancelled.
+func DiffRange(ctx context.Context, store blockstore.Blockstore, roots []cid.Cid, opts ...Option) <-chan RangeDiff {
+	out := make(chan RangeDiff)
+	go func() {
+		defer close(out)
+		if len(roots) < 2 {
+			return
+		}
+		conf := config{}
+		for _, o := range opts {
+			o(&conf)
+		}
+		shallow := conf
+		shallow.ExpandActors = false
+		cmpOpts := diffOptions(ctx, store, roots[0], &conf)
+		headOpts := diffOptions(ctx, store, roots[0], &shallow)
+
+		pre, err := loadRangeRoot(ctx, store, roots[0])
+		for i := 1; i < len(roots) && ctx.Err() == nil; i++ {
+			step := RangeDiff{
+				Pre:  roots[i-1],
+				Post: roots[i],
+				Err:  err,
+			}
+			var post *rangeRoot
+			if step.Err == nil {
+				post, step.Err = loadRangeRoot(ctx, store, step.Post)
+			}
+			if step.Err == nil {
+				step.Changes, step.Err = diffRangeStep(pre, post, cmpOpts, headOpts)
+			}
+			select {
+			case out <- step:
+			case <-ctx.Done():
+				return
+			}
+			if step.Err != nil {
+				return
+			}
+			pre = post
+		}
+	}()
+	return out
+}
+
+// rangeRoot is a state root of a range, loaded once to be compared against
+// the roots either side of it.
+type rangeRoot struct {
+	actors map[string]*types.Actor
+	names  map[string]string
+}
+
+func loadRangeRoot(ctx context.Context, store blockstore.Blockstore, root cid.Cid) (*rangeRoot, error) {
+	actors, err := loadStateActors(ctx, store, root)
+	if err != nil {
+		return nil, err
+	}
+	cborStore := cbor.NewCborStore(store)
+	names, err := loadInitNames(ctx, cborStore, root, newInitActorTransformer(adt.WrapStore(ctx, cborStore)))
+	if err != nil {
+		return nil, err
+	}
+	return &rangeRoot{actors, names}, nil
+}
+
+// diffRangeStep collects the changes between two loaded roots, naming actors
+// as the earlier root does. Actors with the same head are compared by
+// `headOpts`, which do not load their state.
+func diffRangeStep(pre, post *rangeRoot, cmpOpts, headOpts []cmp.Option) (*ChangeSet, error) {
+	set := &ChangeSet{
+		Added:    make(map[string]interface{}),
+		Removed:  make(map[string]interface{}),
+		Modified: make(map[string][]Change),
+	}
+	name := func(k string) string {
+		if n, ok := pre.names[string(mustParseAddress(k).Bytes())]; ok {
+			return n
+		}
+		return k
+	}
+	for k, before := range pre.actors {
+		after, ok := post.actors[k]
+		switch {
+		case !ok:
+			set.Removed[name(k)] = before
+		case sameActor(before, after):
+			// unchanged
+		case before.Head.Equals(after.Head):
+			if err := changeActor(set, name(k), before, after, headOpts); err != nil {
+				return nil, fmt.Errorf("diffing actor %s: %w", k, err)
+			}
+		default:
+			if err := changeActor(set, name(k), before, after, cmpOpts); err != nil {
+				return nil, fmt.Errorf("diffing actor %s: %w", k, err)
+			}
+		}
+	}
+	for k, after := range post.actors {
+		if _, ok := pre.actors[k]; !ok {
+			set.Added[name(k)] = after
+		}
+	}
+	return set, nil
+}
+
+// changeActor records the changes to an actor present in both roots in `set`,
+// returning the panic of an option which cannot load the state it expands.
+func changeActor(set *ChangeSet, name string, before, after *types.Actor, opts []cmp.Option) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	r := changeReporter{set: set}
+	cmp.Equal(map[string]*types.Actor{name: before}, map[string]*types.Actor{name: after}, append(opts, cmp.Reporter(&r))...)
+	return nil
 }
 
 func cidTransformer(ctx context.Context, store blockstore.Blockstore, cborStore cbor.IpldStore, atlas map[string]reflect.Type) []cmp.Option {
@@ -380,7 +503,40 @@ func cidAsString(a cid.Cid) string {
 	return a.String()
 }
 
+// newInitActorTransformer renders the state of the init actor for diffing,
+// reading its address map from `store`.
+func newInitActorTransformer(store adt.Store) func(act initActor.State) *initActorState {
+	return func(act initActor.State) *initActorState {
+		am, _ := adt.AsMap(store, act.AddressMap)
+		var val cbg.CborInt
+		m := make(map[string]uint64)
+		am.ForEach(&val, func(k string) error {
+			address, _ := addr.NewFromBytes([]byte(k))
+			m[address.String()] = uint64(val)
+			return nil
+		})
+		return &initActorState{
+			NextID:      act.NextID.String(),
+			NetworkName: act.NetworkName,
+			ADTRoot:     act.AddressMap.String(),
+			ADT:         m,
+		}
+	}
+}
+
 func getInitFor(ctx context.Context, store cbor.IpldStore, root cid.Cid, helper func(act initActor.State) *initActorState) map[string]string {
+	inverseMap, err := loadInitNames(ctx, store, root, helper)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+	}
+	return inverseMap
+}
+
+// loadInitNames maps the binary form of the ID address of each actor of the
+// state tree at `root` to its name: the robust address it was created with,
+// or the name of a singleton actor. The singleton names are returned even if
+// the init actor cannot be read.
+func loadInitNames(ctx context.Context, store cbor.IpldStore, root cid.Cid, helper func(act initActor.State) *initActorState) (map[string]string, error) {
 	inverseMap := make(map[string]string)
 	inverseMap[string(builtin.InitActorAddr.Bytes())] = "<InitActor>"
 	inverseMap[string(builtin.RewardActorAddr.Bytes())] = "<RewardActor>"
@@ -391,22 +547,23 @@ func getInitFor(ctx context.Context, store cbor.IpldStore, root cid.Cid, helper
 	inverseMap[string(builtin.BurntFundsActorAddr.Bytes())] = "<BurntFundsActor>"
 	tree, err := chainState.LoadStateTree(store, root)
 	if err != nil {
-		fmt.Printf("failed to load root State Tree for account mapping\n")
-		return inverseMap
+		return inverseMap, fmt.Errorf("failed to load root State Tree for account mapping: %w", err)
 	}
 	initAct, err := tree.GetActor(builtin.InitActorAddr)
+	if err != nil {
+		return inverseMap, fmt.Errorf("failed to find Init acct in %v: %w", root, err)
+	}
 
 	var initState initActor.State
 	if err := store.Get(ctx, initAct.Head, &initState); err != nil {
-		fmt.Printf("failed to load Init acct @%v: %v\n", initAct.Head, err)
-		return inverseMap
+		return inverseMap, fmt.Errorf("failed to load Init acct @%v: %w", initAct.Head, err)
 	}
 	forward := helper(initState)
 	for k, v := range forward.ADT {
 		address, _ := addr.NewIDAddress(v)
 		inverseMap[string(address.Bytes())] = k
 	}
-	return inverseMap
+	return inverseMap, nil
 }
 
 type hamtNode struct {
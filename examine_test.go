@@ -0,0 +1,112 @@
+package statediff_test
+
+import (
+	"context"
+	"testing"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	accountActor "github.com/filecoin-project/specs-actors/actors/builtin/account"
+	initActor "github.com/filecoin-project/specs-actors/actors/builtin/init"
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/statediff"
+	"github.com/filecoin-project/statediff/testutil"
+)
+
+func TestDiffRange(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+
+	initHead := mustPut(t, store, &initActor.State{
+		AddressMap:  mustHAMT(t, store, nil, 5),
+		NextID:      1002,
+		NetworkName: "test",
+	})
+	initAct := &types.Actor{Code: builtin.InitActorCodeID, Head: initHead, Balance: types.NewInt(0)}
+	// The state of this actor is never stored, so it can only be diffed while
+	// its head is unchanged.
+	unloaded := mustPut(t, testutil.NewMemStore(), &accountActor.State{Address: idAddr(t, 999)})
+	account := func(id, balance uint64) *types.Actor {
+		head := mustPut(t, store, &accountActor.State{Address: idAddr(t, id)})
+		return &types.Actor{Code: builtin.AccountActorCodeID, Head: head, Balance: types.NewInt(balance)}
+	}
+
+	roots := []cid.Cid{
+		mustStateTree(t, store, map[addr.Address]*types.Actor{
+			builtin.InitActorAddr: initAct,
+			idAddr(t, 999):        {Code: builtin.AccountActorCodeID, Head: unloaded, Balance: types.NewInt(1)},
+			idAddr(t, 1000):       account(1000, 1),
+		}),
+		mustStateTree(t, store, map[addr.Address]*types.Actor{
+			builtin.InitActorAddr: initAct,
+			idAddr(t, 999):        {Code: builtin.AccountActorCodeID, Head: unloaded, Balance: types.NewInt(2)},
+			idAddr(t, 1000):       account(1000, 1),
+		}),
+		mustStateTree(t, store, map[addr.Address]*types.Actor{
+			builtin.InitActorAddr: initAct,
+			idAddr(t, 999):        {Code: builtin.AccountActorCodeID, Head: unloaded, Balance: types.NewInt(2)},
+			idAddr(t, 1001):       account(1001, 1),
+		}),
+	}
+
+	var steps []statediff.RangeDiff
+	for step := range statediff.DiffRange(ctx, store, roots, statediff.ExpandActors) {
+		if step.Err != nil {
+			t.Fatal(step.Err)
+		}
+		steps = append(steps, step)
+	}
+	if len(steps) != 2 {
+		t.Fatalf("expected 2 steps, got %d", len(steps))
+	}
+	for i, step := range steps {
+		if step.Pre != roots[i] || step.Post != roots[i+1] {
+			t.Errorf("step %d compares %s to %s", i, step.Pre, step.Post)
+		}
+	}
+
+	first := steps[0].Changes
+	if len(first.Added) != 0 || len(first.Removed) != 0 || len(first.Modified) != 1 || len(first.Modified["t0999"]) == 0 {
+		t.Errorf("unexpected first step changes: %+v", first)
+	}
+	second := steps[1].Changes
+	if _, ok := second.Added["t01001"]; !ok || len(second.Added) != 1 {
+		t.Errorf("expected t01001 added, got %+v", second.Added)
+	}
+	if _, ok := second.Removed["t01000"]; !ok || len(second.Removed) != 1 {
+		t.Errorf("expected t01000 removed, got %+v", second.Removed)
+	}
+	if len(second.Modified) != 0 {
+		t.Errorf("unexpected modifications: %+v", second.Modified)
+	}
+}
+
+func TestDiffRangeMissingState(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+
+	initHead := mustPut(t, store, &initActor.State{
+		AddressMap:  mustHAMT(t, store, nil, 5),
+		NextID:      101,
+		NetworkName: "test",
+	})
+	initAct := &types.Actor{Code: builtin.InitActorCodeID, Head: initHead, Balance: types.NewInt(0)}
+	root := func(head cid.Cid) cid.Cid {
+		return mustStateTree(t, store, map[addr.Address]*types.Actor{
+			builtin.InitActorAddr: initAct,
+			idAddr(t, 100):        {Code: builtin.AccountActorCodeID, Head: head, Balance: types.NewInt(0)},
+		})
+	}
+	head := mustPut(t, store, &accountActor.State{Address: idAddr(t, 100)})
+	missing := mustPut(t, testutil.NewMemStore(), &accountActor.State{Address: idAddr(t, 101)})
+
+	var steps []statediff.RangeDiff
+	for step := range statediff.DiffRange(ctx, store, []cid.Cid{root(head), root(missing), root(head)}, statediff.ExpandActors) {
+		steps = append(steps, step)
+	}
+	if len(steps) != 1 || steps[0].Err == nil {
+		t.Errorf("expected the range to end on its first step, got %+v", steps)
+	}
+}
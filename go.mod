@@ -5,6 +5,7 @@ go 1.14
 require (
 	github.com/evanw/esbuild v0.6.28
 	github.com/filecoin-project/go-address v0.0.3
+	github.com/filecoin-project/go-amt-ipld/v2 v2.1.1-0.20200731171407-e559a0579161
 	github.com/filecoin-project/go-bitfield v0.2.0
 	github.com/filecoin-project/go-state-types v0.0.0-20200911004822-964d6c679cfc
 	github.com/filecoin-project/lotus v0.5.11-0.20200907070510-420a8706da6d
@@ -17,6 +18,7 @@ require (
 	github.com/ipfs/go-hamt-ipld v0.1.1
 	github.com/ipfs/go-ipfs-blockstore v1.0.1
 	github.com/ipfs/go-ipld-cbor v0.0.5-0.20200428170625-a0bd04d3cbdf
+	github.com/ipfs/go-ipld-format v0.2.0
 	github.com/ipld/go-car v0.1.1-0.20200526133713-1c7508d55aae
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/multiformats/go-multiaddr v0.3.1
@@ -24,6 +26,7 @@ require (
 	github.com/urfave/cli/v2 v2.2.0
 	github.com/whyrusleeping/cbor-gen v0.0.0-20200814224545-656e08ce49ee
 	github.com/willscott/go-cmp v0.5.2-0.20200812183318-8affb9542345
+	golang.org/x/sync v0.0.0-20200625203802-6e8e738ad208
 )
 
 replace github.com/filecoin-project/filecoin-ffi => github.com/filecoin-project/statediff/extern/filecoin-ffi v0.0.0-20200904233626-6a3c8611ff64
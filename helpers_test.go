@@ -0,0 +1,96 @@
+package statediff_test
+
+import (
+	"testing"
+
+	addr "github.com/filecoin-project/go-address"
+	abi "github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-ipfs-blockstore"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	marketActor "github.com/filecoin-project/specs-actors/actors/builtin/market"
+
+	"github.com/filecoin-project/statediff"
+	"github.com/filecoin-project/statediff/testutil"
+)
+
+func mustPut(t *testing.T, store blockstore.Blockstore, obj cbg.CBORMarshaler) cid.Cid {
+	t.Helper()
+	c, err := testutil.PutObject(store, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func mustHAMT(t *testing.T, store blockstore.Blockstore, entries map[string]cbg.CBORMarshaler, bitwidth int) cid.Cid {
+	t.Helper()
+	c, err := testutil.PutHAMT(store, entries, bitwidth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func mustStateTree(t *testing.T, store blockstore.Blockstore, actors map[addr.Address]*types.Actor) cid.Cid {
+	t.Helper()
+	c, err := testutil.PutStateTree(store, actors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func mustAMT(t *testing.T, store blockstore.Blockstore, entries map[uint64]cbg.CBORMarshaler) cid.Cid {
+	t.Helper()
+	c, err := testutil.PutAMT(store, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func idAddr(t *testing.T, id uint64) addr.Address {
+	t.Helper()
+	a, err := addr.NewIDAddress(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func testProposal(t *testing.T, client, provider uint64, start abi.ChainEpoch) *marketActor.DealProposal {
+	t.Helper()
+	piece, err := abi.CidBuilder.Sum([]byte("piece"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &marketActor.DealProposal{
+		PieceCID:             piece,
+		PieceSize:            2048,
+		Client:               idAddr(t, client),
+		Provider:             idAddr(t, provider),
+		Label:                "test",
+		StartEpoch:           start,
+		EndEpoch:             start + 100,
+		StoragePricePerEpoch: abi.NewTokenAmount(1),
+		ProviderCollateral:   abi.NewTokenAmount(2),
+		ClientCollateral:     abi.NewTokenAmount(3),
+	}
+}
+
+func mustMarshalJSON(t *testing.T, v interface{}, opts ...statediff.JSONOption) string {
+	t.Helper()
+	data, err := statediff.MarshalJSON(v, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func tokens(n int64) *abi.TokenAmount {
+	amount := abi.NewTokenAmount(n)
+	return &amount
+}
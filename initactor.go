@@ -0,0 +1,82 @@
+package statediff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-ipfs-blockstore"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+)
+
+// InitActorReverseMap decodes the init actor's address map, given its cid,
+// into a map from each assigned actor ID to the address it was assigned to.
+// This allows ID addresses to be shown as the robust address of the actor.
+func InitActorReverseMap(ctx context.Context, c cid.Cid, store blockstore.Blockstore) (map[uint64]addr.Address, error) {
+	table, err := adt.AsMap(adt.WrapStore(ctx, cbor.NewCborStore(store)), c)
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[uint64]addr.Address)
+	var actorID cbg.CborInt
+	if err := table.ForEach(&actorID, func(k string) error {
+		a, err := addr.NewFromBytes([]byte(k))
+		if err != nil {
+			return fmt.Errorf("invalid init actor address key: %w", err)
+		}
+		m[uint64(actorID)] = a
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// errLookupDone stops the walk of the address map once all IDs are found.
+var errLookupDone = errors.New("all IDs found")
+
+// InitActorLookupIDs finds the addresses assigned the given actor IDs, given
+// the cid of the init actor's address map. The map is keyed by address, so
+// finding an ID means walking its entries. The walk stops as soon as every
+// requested ID has been found, but IDs which are missing, or assigned late
+// in the walk, still cost a read of the whole map. To resolve many IDs, or to
+// resolve IDs repeatedly, build an InitActorReverseMap once instead.
+func InitActorLookupIDs(ctx context.Context, c cid.Cid, store blockstore.Blockstore, ids []uint64) (map[uint64]addr.Address, error) {
+	m := make(map[uint64]addr.Address, len(ids))
+	wanted := make(map[uint64]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	if len(wanted) == 0 {
+		return m, nil
+	}
+
+	table, err := adt.AsMap(adt.WrapStore(ctx, cbor.NewCborStore(store)), c)
+	if err != nil {
+		return nil, err
+	}
+	var actorID cbg.CborInt
+	if err := table.ForEach(&actorID, func(k string) error {
+		if !wanted[uint64(actorID)] {
+			return nil
+		}
+		a, err := addr.NewFromBytes([]byte(k))
+		if err != nil {
+			return fmt.Errorf("invalid init actor address key: %w", err)
+		}
+		m[uint64(actorID)] = a
+		if len(m) == len(wanted) {
+			return errLookupDone
+		}
+		return nil
+	}); err != nil && err != errLookupDone {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,897 @@
+package statediff
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-bitfield"
+	abi "github.com/filecoin-project/go-state-types/abi"
+	lotusTypes "github.com/filecoin-project/lotus/chain/types"
+	"github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+type jsonConfig struct {
+	SafeIntegers      bool
+	IntegersAsStrings bool
+	AnnotateTypes     bool
+	HumanSectorSizes  bool
+	HumanDataCaps     bool
+	OmitEmpty         bool
+	BlockTimestamps   bool
+	BytesDecoders     map[string]BytesDecoder
+	KeyTransformer    KeyTransformer
+	ExpandBitfields   bool
+	CompactRanges     bool
+	NullEpochs        bool
+	MaxEntries        int
+	RobustAddresses   map[uint64]addr.Address
+	MethodNames       bool
+	ActorCodes        map[addr.Address]cid.Cid
+	BestEffort        bool
+	Errors            *[]error
+}
+
+// JSONOption customizes how transformed values are rendered by MarshalJSON.
+type JSONOption func(c *jsonConfig)
+
+// maxSafeInteger is the largest integer exactly representable by a javascript number.
+const maxSafeInteger = 1<<53 - 1
+
+// SafeIntegers renders integers beyond the range that javascript numbers can
+// represent exactly as strings, so that browser clients don't lose precision.
+func SafeIntegers(c *jsonConfig) {
+	c.SafeIntegers = true
+}
+
+// IntegersAsStrings renders all integers as strings.
+func IntegersAsStrings(c *jsonConfig) {
+	c.IntegersAsStrings = true
+}
+
+// AnnotateTypes adds a `_type` field naming the go type of each decoded
+// struct, in the same way bitfields are marked. Structs which already have
+// a `_type` field are left as they are.
+func AnnotateTypes(c *jsonConfig) {
+	c.AnnotateTypes = true
+}
+
+// HumanSectorSizes renders sector sizes, such as the `SectorSize` of a miner's
+// info, with binary units like "32GiB" rather than as a count of bytes.
+func HumanSectorSizes(c *jsonConfig) {
+	c.HumanSectorSizes = true
+}
+
+// HumanDataCaps renders the datacaps of verifiers and verified clients with
+// binary units, like "1.5 TiB", rather than as a count of bytes.
+func HumanDataCaps(c *jsonConfig) {
+	c.HumanDataCaps = true
+}
+
+// OmitEmpty skips struct fields holding the zero value of their type, such as
+// 0, empty bytes, or an undefined cid, so that output focuses on set fields.
+func OmitEmpty(c *jsonConfig) {
+	c.OmitEmpty = true
+}
+
+// BlockTimestamps renders the `Timestamp` of block headers as an RFC3339 date
+// rather than as seconds since the unix epoch.
+func BlockTimestamps(c *jsonConfig) {
+	c.BlockTimestamps = true
+}
+
+// BytesDecoder interprets the contents of a bytes field. It returns false
+// when the bytes cannot be decoded, in which case they render as base64.
+type BytesDecoder func(data []byte) (interface{}, bool)
+
+// DecodeBytes expands the bytes held in `field`, named by the go type of its
+// struct and the field name as in `power.CronEvent.Payload`, with the given
+// decoder, rather than rendering them as base64.
+func DecodeBytes(field string, d BytesDecoder) JSONOption {
+	return func(c *jsonConfig) {
+		if c.BytesDecoders == nil {
+			c.BytesDecoders = make(map[string]BytesDecoder)
+		}
+		c.BytesDecoders[field] = d
+	}
+}
+
+// CBORBytesDecoder is a BytesDecoder for fields holding a cbor encoded value
+// of the type returned by `newValue`.
+func CBORBytesDecoder(newValue func() cbg.CBORUnmarshaler) BytesDecoder {
+	return func(data []byte) (interface{}, bool) {
+		dest := newValue()
+		if err := dest.UnmarshalCBOR(bytes.NewReader(data)); err != nil {
+			return nil, false
+		}
+		return dest, true
+	}
+}
+
+// KeyTransformer chooses how a map key is rendered. It is given the key, such
+// as an abi.ChainEpoch or address.Address, along with the string it would be
+// rendered as by default, and returns the string to emit.
+type KeyTransformer func(key interface{}, rendered string) string
+
+// TransformKeys renders every map key through `t`.
+func TransformKeys(t KeyTransformer) JSONOption {
+	return func(c *jsonConfig) {
+		c.KeyTransformer = t
+	}
+}
+
+// ExpandBitfields renders bitfields, such as the sectors in a precommit
+// expiry queue, as the list of numbers they hold rather than their encoding.
+func ExpandBitfields(c *jsonConfig) {
+	c.ExpandBitfields = true
+}
+
+// CompactRanges renders the sectors of bitfields expanded by ExpandBitfields,
+// and lists of deal IDs (`abi.DealID`) and sector numbers
+// (`abi.SectorNumber`), as runs of consecutive values in the form
+// `[[first,last],...]`. Other lists of integers are rendered as they are.
+func CompactRanges(c *jsonConfig) {
+	c.CompactRanges = true
+}
+
+// NullEpochs renders epochs of -1, used by actors to mark an event that has
+// not happened, such as a deal which has not started or been slashed, as null.
+func NullEpochs(c *jsonConfig) {
+	c.NullEpochs = true
+}
+
+// MaxEntries renders at most `n` entries of each map and list, for previews of
+// large collections. A truncated collection is wrapped in an object of the
+// form `{"entries": rendered, "_truncated": count}`, where count is the number
+// of entries left out, so that the marker never mixes with the keys or
+// elements of the collection itself.
+func MaxEntries(n int) JSONOption {
+	return func(c *jsonConfig) {
+		c.MaxEntries = n
+	}
+}
+
+// ResolveAddresses renders ID addresses which appear in `robust`, a reverse
+// map of the init actor such as returned by InitActorReverseMap, in the form
+// `{"id": "f0123", "robust": "f1..."}`. ID addresses without an entry, and
+// map keys, are rendered as they are.
+func ResolveAddresses(robust map[uint64]addr.Address) JSONOption {
+	return func(c *jsonConfig) {
+		c.RobustAddresses = robust
+	}
+}
+
+// MethodNames renders method numbers, such as the `Method` of a message or
+// multisig transaction and the `MethodNum` of a cron entry, as the name of the
+// method on the actor they are sent to. The recipient is the `To` or
+// `Receiver` address alongside the method number, and its actor is found
+// from `codes`, which may be nil, or as one of the singleton actors. Methods
+// of unknown actors are rendered as numbers.
+func MethodNames(codes map[addr.Address]cid.Cid) JSONOption {
+	return func(c *jsonConfig) {
+		c.MethodNames = true
+		c.ActorCodes = codes
+	}
+}
+
+// BestEffort renders values which fail to render, such as an address with
+// invalid bytes, as an error marker of the form
+// `{"_error": message, "_raw": hex}` rather than failing the whole document.
+// `_raw` holds the cbor encoding of the value, when it has one. The errors
+// are appended to `errs`, if it is not nil.
+func BestEffort(errs *[]error) JSONOption {
+	return func(c *jsonConfig) {
+		c.BestEffort = true
+		c.Errors = errs
+	}
+}
+
+// truncationMarker is the key noting the number of entries left out by
+// MaxEntries, and truncatedEntries the key of the entries which were kept.
+const (
+	truncationMarker = "_truncated"
+	truncatedEntries = "entries"
+)
+
+// errorMarker and rawMarker are the keys of values rendered by BestEffort
+// in place of those which failed.
+const (
+	errorMarker = "_error"
+	rawMarker   = "_raw"
+)
+
+// typeAnnotation is the key used to mark objects with their type.
+const typeAnnotation = "_type"
+
+// MarshalJSON renders a transformed value as JSON. Without options, the output
+// matches that of `encoding/json`.
+func MarshalJSON(v interface{}, opts ...JSONOption) ([]byte, error) {
+	conf := jsonConfig{}
+	for _, o := range opts {
+		o(&conf)
+	}
+
+	e := jsonEncoder{conf: &conf}
+	if err := e.encode(reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return e.Bytes(), nil
+}
+
+// PrettyJSON renders a transformed value as indented, human-readable JSON.
+// Map keys are emitted in sorted order, so the output is stable across calls.
+func PrettyJSON(v interface{}, opts ...JSONOption) (string, error) {
+	data, err := MarshalJSON(v, opts...)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "", "  "); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// CanonicalJSON renders a transformed value as minified JSON in which the
+// keys of every object, including those of structs, are sorted. Numbers are
+// kept exactly as MarshalJSON renders them, so transforms of the same state
+// produce identical bytes, suitable for hashing.
+func CanonicalJSON(v interface{}, opts ...JSONOption) ([]byte, error) {
+	data, err := MarshalJSON(v, opts...)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return json.Marshal(generic)
+}
+
+// ToGo converts a transformed value into plain go values that can be ranged
+// over without knowledge of the actor types. It is the value decoded from the
+// MarshalJSON rendering with the same options, so it holds only:
+//
+//	map[string]interface{} for structs and maps
+//	[]interface{}          for lists
+//	string                 for strings, and for the values MarshalJSON
+//	                       renders as strings: addresses, cids, big integers
+//	                       and bytes, which are base64 encoded
+//	int64                  for integers within its range
+//	uint64                 for larger positive integers
+//	float64                for all other numbers
+//	bool, nil              for booleans and nulls
+//
+// Rendering the result with `encoding/json` reproduces the output of
+// CanonicalJSON with the same options.
+func ToGo(v interface{}, opts ...JSONOption) (interface{}, error) {
+	return genericForm(v, opts...)
+}
+
+// genericForm implements ToGo, and is also the form on which EncodeDiff and
+// ApplyDiff operate.
+func genericForm(v interface{}, opts ...JSONOption) (interface{}, error) {
+	data, err := MarshalJSON(v, opts...)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var out interface{}
+	if err := dec.Decode(&out); err != nil {
+		return nil, err
+	}
+	return normalizeNumbers(out), nil
+}
+
+// normalizeNumbers replaces json numbers with the narrowest of int64, uint64
+// and float64 which holds them, the number types that cbor round-trips.
+func normalizeNumbers(v interface{}) interface{} {
+	switch t := v.(type) {
+	case json.Number:
+		if i, err := strconv.ParseInt(string(t), 10, 64); err == nil {
+			return i
+		}
+		if u, err := strconv.ParseUint(string(t), 10, 64); err == nil {
+			return u
+		}
+		f, _ := t.Float64()
+		return f
+	case map[string]interface{}:
+		for k, e := range t {
+			t[k] = normalizeNumbers(e)
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = normalizeNumbers(e)
+		}
+	}
+	return v
+}
+
+// MarshalPath renders only the part of a transformed value found at `path`,
+// a `/` separated list of field names, map keys and list indexes as they
+// appear in the JSON output of the whole value, like `Info/Owner`.
+func MarshalPath(v interface{}, path string, opts ...JSONOption) ([]byte, error) {
+	sub := reflect.ValueOf(v)
+	for _, seg := range strings.Split(strings.Trim(path, "/"), "/") {
+		if seg == "" {
+			continue
+		}
+		next, err := jsonChild(sub, seg)
+		if err != nil {
+			return nil, fmt.Errorf("path %s: %w", path, err)
+		}
+		sub = next
+	}
+	if !sub.IsValid() {
+		return MarshalJSON(nil, opts...)
+	}
+	return MarshalJSON(sub.Interface(), opts...)
+}
+
+// jsonChild finds the value rendered beneath `v` with the given name.
+func jsonChild(v reflect.Value, name string) (reflect.Value, error) {
+	v = indirect(v)
+	switch v.Kind() {
+	case reflect.Struct:
+		var found reflect.Value
+		forEachJSONField(v, func(n string, _ bool, field reflect.Value) error {
+			if n == name && !found.IsValid() {
+				found = field
+			}
+			return nil
+		})
+		if found.IsValid() {
+			return found, nil
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if key, err := mapKeyString(iter.Key()); err == nil && key == name {
+				return iter.Value(), nil
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if i, err := strconv.Atoi(name); err == nil && i >= 0 && i < v.Len() {
+			return v.Index(i), nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("no %s in %s", name, v.Kind())
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+var cborMarshalerType = reflect.TypeOf((*cbg.CBORMarshaler)(nil)).Elem()
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+var sectorSizeType = reflect.TypeOf(abi.SectorSize(0))
+var dealIDType = reflect.TypeOf(abi.DealID(0))
+var sectorNumberType = reflect.TypeOf(abi.SectorNumber(0))
+var chainEpochType = reflect.TypeOf(abi.ChainEpoch(0))
+var blockHeaderType = reflect.TypeOf(lotusTypes.BlockHeader{})
+var addressType = reflect.TypeOf(addr.Address{})
+var methodNumType = reflect.TypeOf(abi.MethodNum(0))
+var bitFieldType = reflect.TypeOf(bitfield.BitField{})
+var jsonBitFieldType = reflect.TypeOf(JSONBitField{})
+var jsonDataCapType = reflect.TypeOf(JSONDataCap{})
+
+// jsonEncoder walks values in the same way as `encoding/json`, but allows
+// the rendering of individual values to be customized.
+type jsonEncoder struct {
+	bytes.Buffer
+	conf *jsonConfig
+}
+
+func (e *jsonEncoder) encode(v reflect.Value) error {
+	if !e.conf.BestEffort {
+		return e.encodeValue(v)
+	}
+	start := e.Len()
+	err := e.encodeValue(v)
+	if err == nil {
+		return nil
+	}
+	e.Truncate(start)
+	if e.conf.Errors != nil {
+		*e.conf.Errors = append(*e.conf.Errors, err)
+	}
+	e.WriteByte('{')
+	e.writeKey(errorMarker)
+	e.encodeValue(reflect.ValueOf(err.Error()))
+	if m, ok := asMarshaler(v, cborMarshalerType); ok && !(v.Kind() == reflect.Ptr && v.IsNil()) {
+		var raw bytes.Buffer
+		if m.(cbg.CBORMarshaler).MarshalCBOR(&raw) == nil {
+			e.WriteByte(',')
+			e.writeKey(rawMarker)
+			e.encodeValue(reflect.ValueOf(hex.EncodeToString(raw.Bytes())))
+		}
+	}
+	e.WriteByte('}')
+	return nil
+}
+
+func (e *jsonEncoder) encodeValue(v reflect.Value) error {
+	if !v.IsValid() {
+		e.WriteString("null")
+		return nil
+	}
+
+	if e.conf.ExpandBitfields && (v.Type() == bitFieldType || v.Type() == jsonBitFieldType) {
+		return e.encodeBitField(v)
+	}
+
+	if e.conf.HumanDataCaps && v.Type() == jsonDataCapType {
+		return e.encode(reflect.ValueOf(lotusTypes.SizeStr(v.Interface().(JSONDataCap).DataCap)))
+	}
+	if e.conf.RobustAddresses != nil && v.Type() == addressType {
+		if ok, err := e.encodeResolvedAddress(v.Interface().(addr.Address)); ok || err != nil {
+			return err
+		}
+	}
+
+	if m, ok := asMarshaler(v, jsonMarshalerType); ok {
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			e.WriteString("null")
+			return nil
+		}
+		data, err := m.(json.Marshaler).MarshalJSON()
+		if err != nil {
+			return fmt.Errorf("rendering %s: %w", v.Type(), err)
+		}
+		return json.Compact(&e.Buffer, data)
+	}
+
+	if e.conf.NullEpochs && v.Type() == chainEpochType && v.Int() == -1 {
+		e.WriteString("null")
+		return nil
+	}
+	if e.conf.HumanSectorSizes && v.Type() == sectorSizeType {
+		return e.encode(reflect.ValueOf(v.Interface().(abi.SectorSize).ShortString()))
+	}
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			e.WriteString("null")
+			return nil
+		}
+		return e.encode(v.Elem())
+	case reflect.Bool:
+		e.WriteString(strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := v.Int()
+		e.encodeInteger(strconv.FormatInt(i, 10), i > maxSafeInteger || i < -maxSafeInteger)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := v.Uint()
+		e.encodeInteger(strconv.FormatUint(u, 10), u > maxSafeInteger)
+	case reflect.Float32, reflect.Float64, reflect.String:
+		data, err := json.Marshal(v.Interface())
+		if err != nil {
+			return err
+		}
+		e.Write(data)
+	case reflect.Struct:
+		return e.encodeStruct(v)
+	case reflect.Map:
+		return e.encodeMap(v)
+	case reflect.Slice:
+		if v.IsNil() {
+			e.WriteString("null")
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.WriteByte('"')
+			e.WriteString(base64.StdEncoding.EncodeToString(v.Bytes()))
+			e.WriteByte('"')
+			return nil
+		}
+		return e.encodeList(v)
+	case reflect.Array:
+		return e.encodeList(v)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func (e *jsonEncoder) encodeInteger(digits string, unsafe bool) {
+	if e.conf.IntegersAsStrings || (e.conf.SafeIntegers && unsafe) {
+		e.WriteByte('"')
+		e.WriteString(digits)
+		e.WriteByte('"')
+		return
+	}
+	e.WriteString(digits)
+}
+
+func (e *jsonEncoder) encodeBitField(v reflect.Value) error {
+	var bf bitfield.BitField
+	if v.Type() == jsonBitFieldType {
+		bf = v.Interface().(JSONBitField).BitField
+	} else {
+		bf = v.Interface().(bitfield.BitField)
+	}
+	if e.conf.CompactRanges {
+		r := rangeWriter{e: e}
+		e.WriteByte('[')
+		if err := bf.ForEach(func(i uint64) error {
+			r.add(i)
+			return nil
+		}); err != nil {
+			return fmt.Errorf("expanding bitfield: %w", err)
+		}
+		r.flush()
+		e.WriteByte(']')
+		return nil
+	}
+
+	e.WriteByte('[')
+	first := true
+	if err := bf.ForEach(func(i uint64) error {
+		if !first {
+			e.WriteByte(',')
+		}
+		first = false
+		e.encodeInteger(strconv.FormatUint(i, 10), i > maxSafeInteger)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("expanding bitfield: %w", err)
+	}
+	e.WriteByte(']')
+	return nil
+}
+
+// encodeResolvedAddress renders an ID address along with its robust address,
+// returning false if there is none to render.
+func (e *jsonEncoder) encodeResolvedAddress(a addr.Address) (bool, error) {
+	if a.Protocol() != addr.ID {
+		return false, nil
+	}
+	id, err := addr.IDFromAddress(a)
+	if err != nil {
+		return false, err
+	}
+	robust, ok := e.conf.RobustAddresses[id]
+	if !ok {
+		return false, nil
+	}
+	e.WriteByte('{')
+	e.writeKey("id")
+	e.encode(reflect.ValueOf(a.String()))
+	e.WriteByte(',')
+	e.writeKey("robust")
+	e.encode(reflect.ValueOf(robust.String()))
+	e.WriteByte('}')
+	return true, nil
+}
+
+// rangeWriter renders a sequence of integers as runs of consecutive values.
+type rangeWriter struct {
+	e           *jsonEncoder
+	open        bool
+	first, last uint64
+	runs        int
+}
+
+func (r *rangeWriter) add(i uint64) {
+	if r.open && i == r.last+1 {
+		r.last = i
+		return
+	}
+	r.flush()
+	r.first, r.last, r.open = i, i, true
+}
+
+func (r *rangeWriter) flush() {
+	if !r.open {
+		return
+	}
+	if r.runs > 0 {
+		r.e.WriteByte(',')
+	}
+	r.e.WriteByte('[')
+	r.e.encodeInteger(strconv.FormatUint(r.first, 10), r.first > maxSafeInteger)
+	r.e.WriteByte(',')
+	r.e.encodeInteger(strconv.FormatUint(r.last, 10), r.last > maxSafeInteger)
+	r.e.WriteByte(']')
+	r.runs++
+	r.open = false
+}
+
+func (e *jsonEncoder) encodeList(v reflect.Value) error {
+	if e.conf.CompactRanges && isIDList(v.Type()) {
+		r := rangeWriter{e: e}
+		e.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			r.add(v.Index(i).Uint())
+		}
+		r.flush()
+		e.WriteByte(']')
+		return nil
+	}
+
+	n := e.limit(v.Len())
+	e.openTruncated(n, v.Len())
+	e.WriteByte('[')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			e.WriteByte(',')
+		}
+		if err := e.encode(v.Index(i)); err != nil {
+			return err
+		}
+	}
+	e.WriteByte(']')
+	e.closeTruncated(n, v.Len())
+	return nil
+}
+
+// limit is the number of a collection's `length` entries to render.
+func (e *jsonEncoder) limit(length int) int {
+	if e.conf.MaxEntries > 0 && length > e.conf.MaxEntries {
+		return e.conf.MaxEntries
+	}
+	return length
+}
+
+// openTruncated starts the wrapper of a collection of which only `n` of
+// `length` entries are rendered, if any are left out.
+func (e *jsonEncoder) openTruncated(n, length int) {
+	if n == length {
+		return
+	}
+	e.WriteByte('{')
+	e.writeKey(truncatedEntries)
+}
+
+// closeTruncated ends the wrapper started by openTruncated, noting the number
+// of entries left out. The count is always a number, whatever the options for
+// rendering integers.
+func (e *jsonEncoder) closeTruncated(n, length int) {
+	if n == length {
+		return
+	}
+	e.WriteByte(',')
+	e.writeKey(truncationMarker)
+	e.WriteString(strconv.Itoa(length - n))
+	e.WriteByte('}')
+}
+
+func (e *jsonEncoder) encodeMap(v reflect.Value) error {
+	if v.IsNil() {
+		e.WriteString("null")
+		return nil
+	}
+
+	type entry struct {
+		key   string
+		value reflect.Value
+	}
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := mapKeyString(iter.Key())
+		if err != nil {
+			return err
+		}
+		if e.conf.KeyTransformer != nil {
+			key = e.conf.KeyTransformer(iter.Key().Interface(), key)
+		}
+		entries = append(entries, entry{key, iter.Value()})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+
+	n := e.limit(len(entries))
+	e.openTruncated(n, len(entries))
+	e.WriteByte('{')
+	for i, ent := range entries[:n] {
+		if i > 0 {
+			e.WriteByte(',')
+		}
+		e.writeKey(ent.key)
+		if err := e.encode(ent.value); err != nil {
+			return err
+		}
+	}
+	e.WriteByte('}')
+	e.closeTruncated(n, len(entries))
+	return nil
+}
+
+func (e *jsonEncoder) encodeStruct(v reflect.Value) error {
+	e.WriteByte('{')
+	first := true
+	if e.conf.AnnotateTypes && !hasJSONField(v, typeAnnotation) {
+		e.writeKey(typeAnnotation)
+		e.encode(reflect.ValueOf(v.Type().String()))
+		first = false
+	}
+	var recipient cid.Cid
+	if e.conf.MethodNames {
+		recipient = e.recipientCode(v)
+	}
+	err := forEachJSONField(v, func(name string, omitEmpty bool, field reflect.Value) error {
+		if (omitEmpty || e.conf.OmitEmpty) && isEmptyValue(field) {
+			return nil
+		}
+		if e.conf.OmitEmpty && field.IsZero() {
+			return nil
+		}
+		if !first {
+			e.WriteByte(',')
+		}
+		first = false
+		e.writeKey(name)
+		if d, ok := e.conf.BytesDecoders[v.Type().String()+"."+name]; ok && field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Uint8 {
+			if decoded, ok := d(field.Bytes()); ok {
+				field = reflect.ValueOf(decoded)
+			}
+		}
+		if e.conf.BlockTimestamps && v.Type() == blockHeaderType && name == "Timestamp" {
+			field = reflect.ValueOf(time.Unix(int64(field.Uint()), 0).UTC().Format(time.RFC3339))
+		}
+		if recipient.Defined() && field.Type() == methodNumType {
+			if method, ok := MethodName(recipient, abi.MethodNum(field.Uint())); ok {
+				field = reflect.ValueOf(method)
+			}
+		}
+		return e.encode(field)
+	})
+	if err != nil {
+		return err
+	}
+	e.WriteByte('}')
+	return nil
+}
+
+// recipientCode finds the code of the actor addressed by a struct holding a
+// method number, or cid.Undef if it is not known.
+func (e *jsonEncoder) recipientCode(v reflect.Value) cid.Cid {
+	code := cid.Undef
+	forEachJSONField(v, func(name string, _ bool, field reflect.Value) error {
+		if (name == "To" || name == "Receiver") && field.Type() == addressType {
+			to := field.Interface().(addr.Address)
+			if c, ok := singletonCodes[to]; ok {
+				code = c
+			} else if c, ok := e.conf.ActorCodes[to]; ok {
+				code = c
+			}
+		}
+		return nil
+	})
+	return code
+}
+
+func (e *jsonEncoder) writeKey(key string) {
+	data, _ := json.Marshal(key)
+	e.Write(data)
+	e.WriteByte(':')
+}
+
+// asMarshaler returns `v` as an implementation of `iface`, taking its address
+// when the interface is implemented with a pointer receiver.
+func asMarshaler(v reflect.Value, iface reflect.Type) (interface{}, bool) {
+	if v.Kind() != reflect.Ptr && v.Kind() != reflect.Interface && reflect.PtrTo(v.Type()).Implements(iface) {
+		if !v.CanAddr() {
+			p := reflect.New(v.Type())
+			p.Elem().Set(v)
+			v = p.Elem()
+		}
+		return v.Addr().Interface(), true
+	}
+	if v.Type().Implements(iface) && v.Kind() != reflect.Interface {
+		return v.Interface(), true
+	}
+	return nil, false
+}
+
+// mapKeyString renders a map key the way `encoding/json` does.
+func mapKeyString(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if m, ok := asMarshaler(k, textMarshalerType); ok {
+		text, err := m.(encoding.TextMarshaler).MarshalText()
+		return string(text), err
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("unsupported map key type %s", k.Type())
+}
+
+// forEachJSONField visits the exported fields of a struct as `encoding/json`
+// would name them, inlining the fields of embedded structs.
+func forEachJSONField(v reflect.Value, cb func(name string, omitEmpty bool, field reflect.Value) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if idx := strings.Index(tag, ","); idx >= 0 {
+			name, opts = tag[:idx], tag[idx+1:]
+		}
+
+		field := v.Field(i)
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && !ft.Implements(jsonMarshalerType) && !reflect.PtrTo(ft).Implements(jsonMarshalerType) {
+				if field.Kind() == reflect.Ptr {
+					if field.IsNil() {
+						continue
+					}
+					field = field.Elem()
+				}
+				if err := forEachJSONField(field, cb); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if err := cb(name, strings.Contains(opts, "omitempty"), field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasJSONField indicates if a struct renders a field with the given name.
+func hasJSONField(v reflect.Value, name string) bool {
+	found := false
+	forEachJSONField(v, func(n string, _ bool, _ reflect.Value) error {
+		found = found || n == name
+		return nil
+	})
+	return found
+}
+
+// isIDList indicates if a list type holds deal IDs or sector numbers, which
+// CompactRanges renders as runs.
+func isIDList(t reflect.Type) bool {
+	return t.Elem() == dealIDType || t.Elem() == sectorNumberType
+}
+
+// isEmptyValue matches the definition of empty used by `omitempty` in `encoding/json`.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
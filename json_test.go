@@ -0,0 +1,173 @@
+package statediff_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	abi "github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/statediff"
+)
+
+func TestMaxEntries(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		v    interface{}
+		opts []statediff.JSONOption
+		want string
+	}{
+		{"short list", []uint64{1, 2}, nil, `[1,2]`},
+		{"long list", []uint64{1, 2, 3, 4}, nil, `{"entries":[1,2],"_truncated":2}`},
+		{"short map", map[string]int{"a": 1, "b": 2}, nil, `{"a":1,"b":2}`},
+		{"long map", map[string]int{"a": 1, "b": 2, "c": 3}, nil, `{"entries":{"a":1,"b":2},"_truncated":1}`},
+		{"colliding key", map[string]int{"_truncated": 1, "a": 2, "b": 3}, nil, `{"entries":{"_truncated":1,"a":2},"_truncated":1}`},
+		{"nested", [][]uint64{{1, 2, 3}}, nil, `[{"entries":[1,2],"_truncated":1}]`},
+		{"integers as strings", []uint64{1, 2, 3}, []statediff.JSONOption{statediff.IntegersAsStrings}, `{"entries":["1","2"],"_truncated":1}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := append([]statediff.JSONOption{statediff.MaxEntries(2)}, tc.opts...)
+			if js := mustMarshalJSON(t, tc.v, opts...); js != tc.want {
+				t.Errorf("rendered as %s, want %s", js, tc.want)
+			}
+		})
+	}
+}
+
+func TestCompactRanges(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{"deal IDs", []abi.DealID{1, 2, 3, 7}, `[[1,3],[7,7]]`},
+		{"sector numbers", []abi.SectorNumber{4, 5, 9, 10}, `[[4,5],[9,10]]`},
+		{"empty", []abi.DealID{}, `[]`},
+		{"plain integers", []uint64{1, 2, 3}, `[1,2,3]`},
+		{"sector sizes", []abi.SectorSize{2048, 2049}, `[2048,2049]`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if js := mustMarshalJSON(t, tc.v, statediff.CompactRanges); js != tc.want {
+				t.Errorf("rendered as %s, want %s", js, tc.want)
+			}
+		})
+	}
+}
+
+func TestToGoRoundTrip(t *testing.T) {
+	deal := testProposal(t, 100, 1000, 10)
+	for _, tc := range []struct {
+		name string
+		v    interface{}
+		opts []statediff.JSONOption
+	}{
+		{"deal proposal", deal, nil},
+		{"annotated deal proposal", deal, []statediff.JSONOption{statediff.AnnotateTypes}},
+		{"large integers", []uint64{1, 1 << 63}, nil},
+		{"bytes", struct{ Data []byte }{[]byte("statediff")}, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := statediff.ToGo(tc.v, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.Marshal(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := statediff.CanonicalJSON(tc.v, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("round trips as %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestToGoTypes(t *testing.T) {
+	out, err := statediff.ToGo(struct {
+		Small  int64
+		Large  uint64
+		Data   []byte
+		Client interface{}
+		List   []int64
+	}{-1, 1 << 63, []byte("statediff"), idAddr(t, 100), []int64{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"Small":  int64(-1),
+		"Large":  uint64(1 << 63),
+		"Data":   base64.StdEncoding.EncodeToString([]byte("statediff")),
+		"Client": "t0100",
+		"List":   []interface{}{int64(1)},
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("converted to %#v, want %#v", out, want)
+	}
+}
+
+func TestIntegerRendering(t *testing.T) {
+	// maxSafe is the largest integer a javascript number holds exactly.
+	const maxSafe = 1<<53 - 1
+	type epochs struct {
+		Start abi.ChainEpoch
+		End   abi.ChainEpoch
+		Sizes []uint64
+	}
+	v := epochs{Start: 10, End: -1 << 60, Sizes: []uint64{maxSafe, maxSafe + 1}}
+	for _, tc := range []struct {
+		name string
+		opts []statediff.JSONOption
+		want string
+	}{
+		{"default", nil, `{"Start":10,"End":-1152921504606846976,"Sizes":[9007199254740991,9007199254740992]}`},
+		{"safe integers", []statediff.JSONOption{statediff.SafeIntegers}, `{"Start":10,"End":"-1152921504606846976","Sizes":[9007199254740991,"9007199254740992"]}`},
+		{"integers as strings", []statediff.JSONOption{statediff.IntegersAsStrings}, `{"Start":"10","End":"-1152921504606846976","Sizes":["9007199254740991","9007199254740992"]}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if js := mustMarshalJSON(t, v, tc.opts...); js != tc.want {
+				t.Errorf("rendered as %s, want %s", js, tc.want)
+			}
+		})
+	}
+}
+
+// unrenderable is a value which fails to render as JSON, but has a cbor form.
+type unrenderable struct{}
+
+func (unrenderable) MarshalJSON() ([]byte, error) {
+	return nil, errors.New("cannot render")
+}
+
+func (unrenderable) MarshalCBOR(w io.Writer) error {
+	_, err := w.Write([]byte{0x07})
+	return err
+}
+
+func TestBestEffort(t *testing.T) {
+	v := struct {
+		Valid  uint64
+		Broken unrenderable
+		List   []interface{}
+	}{1, unrenderable{}, []interface{}{2, unrenderable{}}}
+
+	if _, err := statediff.MarshalJSON(v); err == nil {
+		t.Fatal("rendered a value which cannot render")
+	}
+
+	var errs []error
+	marker := `{"_error":"rendering statediff_test.unrenderable: cannot render","_raw":"07"}`
+	want := `{"Valid":1,"Broken":` + marker + `,"List":[2,` + marker + `]}`
+	if js := mustMarshalJSON(t, v, statediff.BestEffort(&errs)); js != want {
+		t.Errorf("rendered as %s, want %s", js, want)
+	}
+	if len(errs) != 2 {
+		t.Errorf("expected 2 errors, got %v", errs)
+	}
+}
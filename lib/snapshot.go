@@ -0,0 +1,53 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/blockstore"
+	"github.com/filecoin-project/statediff"
+	"github.com/ipld/go-car"
+)
+
+// Snapshot is a chain snapshot, as exported by `lotus chain export`, loaded
+// into memory.
+type Snapshot struct {
+	// Head is the tipset the snapshot was exported from, which the car
+	// names as its roots.
+	Head  *types.TipSet
+	Store blockstore.Blockstore
+}
+
+// LoadSnapshot reads a snapshot car, identifying its head tipset.
+func LoadSnapshot(r io.Reader) (*Snapshot, error) {
+	store := blockstore.NewTemporary()
+	header, err := car.LoadCar(store, r)
+	if err != nil {
+		return nil, err
+	}
+
+	blks := make([]*types.BlockHeader, 0, len(header.Roots))
+	for _, root := range header.Roots {
+		block, err := store.Get(root)
+		if err != nil {
+			return nil, fmt.Errorf("loading head block %s: %w", root, err)
+		}
+		blk, err := types.DecodeBlock(block.RawData())
+		if err != nil {
+			return nil, fmt.Errorf("snapshot root %s is not a block header: %w", root, err)
+		}
+		blks = append(blks, blk)
+	}
+	head, err := types.NewTipSet(blks)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{Head: head, Store: store}, nil
+}
+
+// TransformState decodes the state tree of the snapshot's head tipset.
+func (s *Snapshot) TransformState(ctx context.Context, opts ...statediff.TransformOption) (interface{}, error) {
+	return statediff.Transform(ctx, s.Head.ParentState(), s.Store, string(statediff.LotusTypeStateroot), opts...)
+}
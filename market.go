@@ -0,0 +1,60 @@
+package statediff
+
+import (
+	"context"
+	"fmt"
+
+	abi "github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-ipfs-blockstore"
+	cbor "github.com/ipfs/go-ipld-cbor"
+
+	marketActor "github.com/filecoin-project/specs-actors/actors/builtin/market"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+)
+
+// DealEconomics derives the duration of a deal, and the total price paid for
+// storage over that duration, from a transformed deal proposal, such as an
+// entry of `storageMarketActor.Proposals`.
+func DealEconomics(proposal interface{}) (abi.ChainEpoch, big.Int, error) {
+	var p *marketActor.DealProposal
+	switch v := proposal.(type) {
+	case marketActor.DealProposal:
+		p = &v
+	case *marketActor.DealProposal:
+		p = v
+	default:
+		return 0, big.Zero(), fmt.Errorf("%T is not a deal proposal", proposal)
+	}
+	return p.Duration(), p.TotalStorageFee(), nil
+}
+
+// MarketPendingDeals lists the proposals of the deals of the market actor,
+// given the cid of its state, which are still pending. In v0 the pending
+// proposals table maps each pending proposal cid to the proposal itself, so
+// the proposals are read from it directly, without scanning the proposals of
+// all published deals. Proposals are listed in the order of the table.
+func MarketPendingDeals(ctx context.Context, c cid.Cid, store blockstore.Blockstore) ([]marketActor.DealProposal, error) {
+	cborStore := cbor.NewCborStore(store)
+	adtStore := adt.WrapStore(ctx, cborStore)
+
+	state := marketActor.State{}
+	if err := cborStore.Get(ctx, c, &state); err != nil {
+		return nil, err
+	}
+
+	pending, err := adt.AsMap(adtStore, state.PendingProposals)
+	if err != nil {
+		return nil, err
+	}
+	var deals []marketActor.DealProposal
+	var proposal marketActor.DealProposal
+	if err := pending.ForEach(&proposal, func(k string) error {
+		deals = append(deals, proposal)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return deals, nil
+}
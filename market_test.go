@@ -0,0 +1,88 @@
+package statediff_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	abi "github.com/filecoin-project/go-state-types/abi"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	marketActor "github.com/filecoin-project/specs-actors/actors/builtin/market"
+
+	"github.com/filecoin-project/statediff"
+	"github.com/filecoin-project/statediff/testutil"
+)
+
+func TestMarketPendingDeals(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+
+	activated := testProposal(t, 100, 1000, 10)
+	pendingA := testProposal(t, 101, 1000, 20)
+	pendingB := testProposal(t, 102, 1000, 30)
+	pendingTable := make(map[string]cbg.CBORMarshaler)
+	want := make(map[string]bool)
+	for _, p := range []*marketActor.DealProposal{pendingA, pendingB} {
+		c, err := p.Cid()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pendingTable[string(c.Bytes())] = p
+		want[c.String()] = true
+	}
+
+	emptyMap := mustHAMT(t, store, nil, 5)
+	state := marketActor.ConstructState(mustAMT(t, store, nil), emptyMap, emptyMap)
+	state.Proposals = mustAMT(t, store, map[uint64]cbg.CBORMarshaler{0: activated, 1: pendingA, 2: pendingB})
+	state.PendingProposals = mustHAMT(t, store, pendingTable, 5)
+	root := mustPut(t, store, state)
+
+	deals, err := statediff.MarketPendingDeals(ctx, root, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deals) != len(want) {
+		t.Fatalf("%d pending deals, want %d", len(deals), len(want))
+	}
+	for _, d := range deals {
+		c, err := d.Cid()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !want[c.String()] {
+			t.Errorf("deal of client %s listed as pending", d.Client)
+		}
+		delete(want, c.String())
+	}
+}
+
+func TestMarketProposalsRenderFlagsAndCollateral(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+
+	unverified := testProposal(t, 100, 1000, 10)
+	verified := testProposal(t, 101, 1000, 20)
+	verified.VerifiedDeal = true
+	verified.ProviderCollateral = abi.NewTokenAmount(9007199254740993)
+	root := mustAMT(t, store, map[uint64]cbg.CBORMarshaler{0: unverified, 1: verified})
+
+	v, err := statediff.TransformType(ctx, root, store, statediff.MarketActorProposals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var deals map[string]map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(mustMarshalJSON(t, v)), &deals); err != nil {
+		t.Fatal(err)
+	}
+	for id, want := range map[string]map[string]string{
+		"0": {"VerifiedDeal": `false`, "ProviderCollateral": `"2"`, "ClientCollateral": `"3"`},
+		"1": {"VerifiedDeal": `true`, "ProviderCollateral": `"9007199254740993"`, "ClientCollateral": `"3"`},
+	} {
+		for field, rendered := range want {
+			if got := string(deals[id][field]); got != rendered {
+				t.Errorf("deal %s %s rendered as %s, want %s", id, field, got, rendered)
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package statediff
+
+import (
+	"reflect"
+
+	addr "github.com/filecoin-project/go-address"
+	abi "github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+)
+
+// methodTables holds the method numbers of each builtin actor, as structs
+// with a field named for each method.
+var methodTables = map[cid.Cid]interface{}{
+	builtin.AccountActorCodeID:          builtin.MethodsAccount,
+	builtin.InitActorCodeID:             builtin.MethodsInit,
+	builtin.CronActorCodeID:             builtin.MethodsCron,
+	builtin.RewardActorCodeID:           builtin.MethodsReward,
+	builtin.MultisigActorCodeID:         builtin.MethodsMultisig,
+	builtin.PaymentChannelActorCodeID:   builtin.MethodsPaych,
+	builtin.StorageMarketActorCodeID:    builtin.MethodsMarket,
+	builtin.StoragePowerActorCodeID:     builtin.MethodsPower,
+	builtin.StorageMinerActorCodeID:     builtin.MethodsMiner,
+	builtin.VerifiedRegistryActorCodeID: builtin.MethodsVerifiedRegistry,
+}
+
+// singletonCodes are the code of the actors found at well known addresses.
+var singletonCodes = map[addr.Address]cid.Cid{
+	builtin.InitActorAddr:             builtin.InitActorCodeID,
+	builtin.RewardActorAddr:           builtin.RewardActorCodeID,
+	builtin.CronActorAddr:             builtin.CronActorCodeID,
+	builtin.StoragePowerActorAddr:     builtin.StoragePowerActorCodeID,
+	builtin.StorageMarketActorAddr:    builtin.StorageMarketActorCodeID,
+	builtin.VerifiedRegistryActorAddr: builtin.VerifiedRegistryActorCodeID,
+}
+
+// MethodName names method `num` of the builtin actor with the given code.
+// Sending funds, method 0, is named "Send" for every actor.
+func MethodName(code cid.Cid, num abi.MethodNum) (string, bool) {
+	if num == builtin.MethodSend {
+		return "Send", true
+	}
+	table, ok := methodTables[code]
+	if !ok {
+		return "", false
+	}
+	v := reflect.ValueOf(table)
+	for i := 0; i < v.NumField(); i++ {
+		if abi.MethodNum(v.Field(i).Uint()) == num {
+			return v.Type().Field(i).Name, true
+		}
+	}
+	return "", false
+}
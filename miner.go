@@ -0,0 +1,225 @@
+package statediff
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/filecoin-project/go-bitfield"
+	abi "github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-ipfs-blockstore"
+	cbor "github.com/ipfs/go-ipld-cbor"
+
+	storageMinerActor "github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+)
+
+// DeadlineSummary is the aggregate state of a single miner deadline.
+type DeadlineSummary struct {
+	Partitions        uint64
+	LiveSectors       uint64
+	TotalSectors      uint64
+	FaultySectors     uint64
+	RecoveringSectors uint64
+	FaultyPower       storageMinerActor.PowerPair
+}
+
+// MinerDeadlineSummary summarizes each of a miner's deadlines, given the cid of
+// the miner's `Deadlines`. The deadline itself tracks faults as power rather
+// than as a number of sectors, so faulty and recovering sectors are counted
+// from the bitfields of the deadline's partitions, without loading any sector
+// information. Blocks are read as TransformType reads them with `opts`, so
+// that, for instance, MaxMemory bounds the read and a missing block is
+// reported as a *BlockNotFoundError. The other miner helpers take options in
+// the same way.
+func MinerDeadlineSummary(ctx context.Context, c cid.Cid, store blockstore.Blockstore, opts ...TransformOption) ([]DeadlineSummary, error) {
+	cborStore := cbor.NewCborStore(configuredStore(store, opts))
+	adtStore := adt.WrapStore(ctx, cborStore)
+
+	deadlines := storageMinerActor.Deadlines{}
+	if err := cborStore.Get(ctx, c, &deadlines); err != nil {
+		return nil, err
+	}
+
+	summaries := make([]DeadlineSummary, len(deadlines.Due))
+	for i, dc := range deadlines.Due {
+		deadline := storageMinerActor.Deadline{}
+		if err := cborStore.Get(ctx, dc, &deadline); err != nil {
+			return nil, err
+		}
+		partitions, err := adt.AsArray(adtStore, deadline.Partitions)
+		if err != nil {
+			return nil, err
+		}
+		summary := DeadlineSummary{
+			Partitions:   partitions.Length(),
+			LiveSectors:  deadline.LiveSectors,
+			TotalSectors: deadline.TotalSectors,
+			FaultyPower:  deadline.FaultyPower,
+		}
+		var partition storageMinerActor.Partition
+		if err := partitions.ForEach(&partition, func(p int64) error {
+			faulty, err := partition.Faults.Count()
+			if err != nil {
+				return fmt.Errorf("partition %d: %w", p, err)
+			}
+			recovering, err := partition.Recoveries.Count()
+			if err != nil {
+				return fmt.Errorf("partition %d: %w", p, err)
+			}
+			summary.FaultySectors += faulty
+			summary.RecoveringSectors += recovering
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("deadline %d: %w", i, err)
+		}
+		summaries[i] = summary
+	}
+	return summaries, nil
+}
+
+// ProvingInfo locates a miner within its proving period at an epoch.
+type ProvingInfo struct {
+	Epoch              abi.ChainEpoch
+	ProvingPeriodStart abi.ChainEpoch
+	CurrentDeadline    uint64
+	Deadlines          uint64
+	// The window in which proofs for the current deadline may be submitted.
+	DeadlineOpen  abi.ChainEpoch
+	DeadlineClose abi.ChainEpoch
+	// Whether the window is open at Epoch.
+	DeadlineIsOpen bool
+	// Whether Epoch is past the end of the proving period, which happens when
+	// the state has not yet been advanced by the end of period cron callback.
+	PeriodElapsed bool
+}
+
+// MinerProvingInfo reports the proving period and current deadline of a
+// miner at `epoch`, the current epoch, given the cid of its state, without
+// loading any of the structures the state links to.
+func MinerProvingInfo(ctx context.Context, c cid.Cid, store blockstore.Blockstore, epoch abi.ChainEpoch, opts ...TransformOption) (*ProvingInfo, error) {
+	cborStore := cbor.NewCborStore(configuredStore(store, opts))
+
+	state := storageMinerActor.State{}
+	if err := cborStore.Get(ctx, c, &state); err != nil {
+		return nil, err
+	}
+
+	deadline := state.DeadlineInfo(epoch)
+	return &ProvingInfo{
+		Epoch:              epoch,
+		ProvingPeriodStart: state.ProvingPeriodStart,
+		CurrentDeadline:    state.CurrentDeadline,
+		Deadlines:          storageMinerActor.WPoStPeriodDeadlines,
+		DeadlineOpen:       deadline.Open,
+		DeadlineClose:      deadline.Close,
+		DeadlineIsOpen:     deadline.IsOpen(),
+		PeriodElapsed:      deadline.PeriodElapsed(),
+	}, nil
+}
+
+// MinerVestingTotal sums the funds of a miner that remain locked at `epoch`,
+// given the cid of the miner's `VestingFunds`. Funds scheduled for an epoch
+// are unlocked once that epoch has passed.
+func MinerVestingTotal(ctx context.Context, c cid.Cid, store blockstore.Blockstore, epoch abi.ChainEpoch, opts ...TransformOption) (big.Int, error) {
+	cborStore := cbor.NewCborStore(configuredStore(store, opts))
+
+	funds := storageMinerActor.VestingFunds{}
+	if err := cborStore.Get(ctx, c, &funds); err != nil {
+		return big.Zero(), err
+	}
+
+	total := big.Zero()
+	for _, fund := range funds.Funds {
+		if fund.Epoch >= epoch {
+			total = big.Add(total, fund.Amount)
+		}
+	}
+	return total, nil
+}
+
+// MinerSectorStateCounts counts the active, faulty and recovering sectors of
+// a miner across all of its partitions, given the cid of the miner's state.
+// Counts are taken from the partition bitfields, so no sector information is
+// loaded. Active sectors are those neither faulty nor terminated.
+func MinerSectorStateCounts(ctx context.Context, c cid.Cid, store blockstore.Blockstore, opts ...TransformOption) (active, faulty, recovering uint64, err error) {
+	cborStore := cbor.NewCborStore(configuredStore(store, opts))
+	adtStore := adt.WrapStore(ctx, cborStore)
+
+	state := storageMinerActor.State{}
+	if err = cborStore.Get(ctx, c, &state); err != nil {
+		return
+	}
+	deadlines := storageMinerActor.Deadlines{}
+	if err = cborStore.Get(ctx, state.Deadlines, &deadlines); err != nil {
+		return
+	}
+
+	for _, dc := range deadlines.Due {
+		deadline := storageMinerActor.Deadline{}
+		if err = cborStore.Get(ctx, dc, &deadline); err != nil {
+			return
+		}
+		var partitions *adt.Array
+		if partitions, err = adt.AsArray(adtStore, deadline.Partitions); err != nil {
+			return
+		}
+		var partition storageMinerActor.Partition
+		err = partitions.ForEach(&partition, func(i int64) error {
+			activeSectors, err := partition.ActiveSectors()
+			if err != nil {
+				return err
+			}
+			counts := []struct {
+				into *uint64
+				bf   bitfield.BitField
+			}{
+				{&active, activeSectors},
+				{&faulty, partition.Faults},
+				{&recovering, partition.Recoveries},
+			}
+			for _, count := range counts {
+				n, err := count.bf.Count()
+				if err != nil {
+					return fmt.Errorf("partition %d: %w", i, err)
+				}
+				*count.into += n
+			}
+			return nil
+		})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// MinerDeadlinePowerSummary reports the live and faulty power of a single
+// deadline, given its cid. Faulty power is held by the deadline itself. The
+// deadlines of v0 miners do not record their live power, so it is summed
+// from the deadline's partitions, without loading their sectors or
+// expiration queues.
+func MinerDeadlinePowerSummary(ctx context.Context, c cid.Cid, store blockstore.Blockstore, opts ...TransformOption) (live, faulty storageMinerActor.PowerPair, err error) {
+	cborStore := cbor.NewCborStore(configuredStore(store, opts))
+
+	deadline := storageMinerActor.Deadline{}
+	if err = cborStore.Get(ctx, c, &deadline); err != nil {
+		return
+	}
+	partitions, err := adt.AsArray(adt.WrapStore(ctx, cborStore), deadline.Partitions)
+	if err != nil {
+		return
+	}
+
+	live = storageMinerActor.NewPowerPairZero()
+	var partition storageMinerActor.Partition
+	if err = partitions.ForEach(&partition, func(int64) error {
+		live = live.Add(partition.LivePower)
+		return nil
+	}); err != nil {
+		return
+	}
+	faulty = deadline.FaultyPower
+	return
+}
@@ -0,0 +1,171 @@
+package statediff_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/filecoin-project/go-bitfield"
+	abi "github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-ipfs-blockstore"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	storageMinerActor "github.com/filecoin-project/specs-actors/actors/builtin/miner"
+
+	"github.com/filecoin-project/statediff"
+	"github.com/filecoin-project/statediff/testutil"
+)
+
+func TestMinerDeadlineSummarySectorCounts(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+	emptyArray := mustAMT(t, store, nil)
+
+	partition := func(sectors, faults, recoveries []uint64) *storageMinerActor.Partition {
+		p := storageMinerActor.ConstructPartition(emptyArray)
+		p.Sectors = bitfield.NewFromSet(sectors)
+		p.Faults = bitfield.NewFromSet(faults)
+		p.Recoveries = bitfield.NewFromSet(recoveries)
+		return p
+	}
+	deadline := storageMinerActor.ConstructDeadline(emptyArray)
+	deadline.Partitions = mustAMT(t, store, map[uint64]cbg.CBORMarshaler{
+		0: partition([]uint64{1, 2, 3, 4}, []uint64{2, 3}, []uint64{3}),
+		1: partition([]uint64{5, 6, 7}, []uint64{5, 6, 7}, []uint64{5, 7}),
+	})
+	deadline.LiveSectors = 7
+	deadline.TotalSectors = 7
+
+	deadlines := storageMinerActor.ConstructDeadlines(mustPut(t, store, storageMinerActor.ConstructDeadline(emptyArray)))
+	deadlines.Due[3] = mustPut(t, store, deadline)
+	root := mustPut(t, store, deadlines)
+
+	summaries, err := statediff.MinerDeadlineSummary(ctx, root, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != len(deadlines.Due) {
+		t.Fatalf("%d deadlines summarized, want %d", len(summaries), len(deadlines.Due))
+	}
+	for i, s := range summaries {
+		want := statediff.DeadlineSummary{}
+		if i == 3 {
+			want = statediff.DeadlineSummary{Partitions: 2, LiveSectors: 7, TotalSectors: 7, FaultySectors: 5, RecoveringSectors: 3}
+		}
+		s.FaultyPower = storageMinerActor.PowerPair{}
+		if s != want {
+			t.Errorf("deadline %d summarized as %+v, want %+v", i, s, want)
+		}
+	}
+}
+
+func TestMinerBalancesRenderAsDecimals(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+	state := testMinerState(t, store, 0)
+	// Large enough to lose precision as a float, and to be mangled if it were
+	// rendered as the bytes of its cbor form.
+	state.PreCommitDeposits = abi.NewTokenAmount(9007199254740993)
+	state.LockedFunds = big.Mul(abi.NewTokenAmount(1000000000000000000), abi.NewTokenAmount(1000))
+	state.InitialPledgeRequirement = abi.NewTokenAmount(7)
+	root := mustPut(t, store, state)
+
+	v, err := statediff.TransformType(ctx, root, store, statediff.StorageMinerActorState)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(mustMarshalJSON(t, v)), &fields); err != nil {
+		t.Fatal(err)
+	}
+	for field, want := range map[string]string{
+		"PreCommitDeposits":        `"9007199254740993"`,
+		"LockedFunds":              `"1000000000000000000000"`,
+		"InitialPledgeRequirement": `"7"`,
+	} {
+		if got := string(fields[field]); got != want {
+			t.Errorf("%s rendered as %s, want %s", field, got, want)
+		}
+	}
+}
+
+func TestMinerProvingInfo(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+	state := testMinerState(t, store, 1000)
+	state.CurrentDeadline = 2
+	root := mustPut(t, store, state)
+
+	open := 1000 + 2*storageMinerActor.WPoStChallengeWindow
+	for _, tc := range []struct {
+		name    string
+		epoch   abi.ChainEpoch
+		isOpen  bool
+		elapsed bool
+	}{
+		{"before window", open - 1, false, false},
+		{"in window", open, true, false},
+		{"after period", 1000 + storageMinerActor.WPoStProvingPeriod, false, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			info, err := statediff.MinerProvingInfo(ctx, root, store, tc.epoch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := statediff.ProvingInfo{
+				Epoch:              tc.epoch,
+				ProvingPeriodStart: 1000,
+				CurrentDeadline:    2,
+				Deadlines:          storageMinerActor.WPoStPeriodDeadlines,
+				DeadlineOpen:       open,
+				DeadlineClose:      open + storageMinerActor.WPoStChallengeWindow,
+				DeadlineIsOpen:     tc.isOpen,
+				PeriodElapsed:      tc.elapsed,
+			}
+			if *info != want {
+				t.Errorf("proving info %+v, want %+v", *info, want)
+			}
+		})
+	}
+}
+
+// testMinerState constructs the state of a miner with no sectors, whose
+// proving period starts at `periodStart`.
+func testMinerState(t *testing.T, store blockstore.Blockstore, periodStart abi.ChainEpoch) *storageMinerActor.State {
+	t.Helper()
+	emptyArray := mustAMT(t, store, nil)
+	info, err := storageMinerActor.ConstructMinerInfo(idAddr(t, 100), idAddr(t, 101), nil, []byte("peer"), nil, abi.RegisteredSealProof_StackedDrg2KiBV1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadlines := storageMinerActor.ConstructDeadlines(mustPut(t, store, storageMinerActor.ConstructDeadline(emptyArray)))
+	state, err := storageMinerActor.ConstructState(mustPut(t, store, info), periodStart, mustPut(t, store, bitfield.New()), emptyArray,
+		mustHAMT(t, store, nil, 5), mustPut(t, store, deadlines), mustPut(t, store, storageMinerActor.ConstructVestingFunds()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
+
+func TestMinerHelpersConfiguredStore(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+	state := testMinerState(t, store, 0)
+	root := mustPut(t, store, state)
+	missing := mustPut(t, testutil.NewMemStore(), &storageMinerActor.VestingFunds{
+		Funds: []storageMinerActor.VestingFund{{Epoch: 10, Amount: abi.NewTokenAmount(1)}},
+	})
+
+	var notFound *statediff.BlockNotFoundError
+	if _, err := statediff.MinerVestingTotal(ctx, missing, store, 0); !errors.As(err, &notFound) {
+		t.Errorf("expected a BlockNotFoundError, got %v", err)
+	}
+	if _, _, _, err := statediff.MinerSectorStateCounts(ctx, root, store, statediff.MaxMemory(1)); !errors.Is(err, statediff.ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+	if _, _, _, err := statediff.MinerSectorStateCounts(ctx, root, store); err != nil {
+		t.Errorf("counting sectors without a limit: %v", err)
+	}
+}
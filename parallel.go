@@ -0,0 +1,186 @@
+package statediff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/blockstore"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/willscott/go-cmp/cmp"
+	"golang.org/x/sync/errgroup"
+)
+
+// DiffParallel compares stateroots `a` and `b` as Diff does, but diffs each
+// actor present in both roots independently, on up to `workers` actors at a
+// time. Actors added or removed between the roots are reported by their type
+// and head. The changes to individual actors are joined in order of actor ID.
+// The first actor which fails to diff, such as when its state is missing from
+// the store, cancels the remaining diffs and its error is returned, as is the
+// error of `ctx` if it is cancelled.
+func DiffParallel(ctx context.Context, store blockstore.Blockstore, a, b cid.Cid, workers int, opts ...Option) (string, error) {
+	conf := config{}
+	for _, o := range opts {
+		o(&conf)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	before, err := loadStateActors(ctx, store, a)
+	if err != nil {
+		return "", err
+	}
+	after, err := loadStateActors(ctx, store, b)
+	if err != nil {
+		return "", err
+	}
+
+	var added, removed, common []string
+	for k, act := range before {
+		other, ok := after[k]
+		switch {
+		case !ok:
+			removed = append(removed, k)
+		case !sameActor(act, other):
+			common = append(common, k)
+		}
+	}
+	for k := range after {
+		if _, ok := before[k]; !ok {
+			added = append(added, k)
+		}
+	}
+
+	cborStore := cbor.NewCborStore(store)
+	names, err := loadInitNames(ctx, cborStore, a, newInitActorTransformer(adt.WrapStore(ctx, cborStore)))
+	if err != nil {
+		return "", err
+	}
+
+	diffs := make([]string, len(common))
+	g, gctx := errgroup.WithContext(ctx)
+	cmpOpts := diffOptions(gctx, store, a, &conf)
+	jobs := make(chan int)
+	g.Go(func() error {
+		defer close(jobs)
+		for i := range common {
+			select {
+			case jobs <- i:
+			case <-gctx.Done():
+				return gctx.Err()
+			}
+		}
+		return nil
+	})
+	for w := 0; w < workers; w++ {
+		g.Go(func() error {
+			for i := range jobs {
+				text, err := diffActor(before[common[i]], after[common[i]], cmpOpts)
+				if err != nil {
+					return fmt.Errorf("diffing actor %s: %w", common[i], err)
+				}
+				diffs[i] = text
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return "", err
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	type change struct {
+		address addr.Address
+		text    string
+	}
+	changes := make([]change, 0, len(added)+len(removed)+len(common))
+	for _, k := range added {
+		changes = append(changes, change{mustParseAddress(k), describeActor("+", after[k])})
+	}
+	for _, k := range removed {
+		changes = append(changes, change{mustParseAddress(k), describeActor("-", before[k])})
+	}
+	for i, k := range common {
+		changes = append(changes, change{mustParseAddress(k), diffs[i]})
+	}
+	sort.Slice(changes, func(i, j int) bool { return actorLess(changes[i].address, changes[j].address) })
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", a, b)
+	for _, c := range changes {
+		name, ok := names[string(c.address.Bytes())]
+		if !ok {
+			name = c.address.String()
+		}
+		fmt.Fprintf(&out, "@@ %s @@\n%s", name, c.text)
+	}
+	return out.String(), nil
+}
+
+// diffActor compares an actor present in both roots. The comparison options
+// panic when they cannot load the state they expand, which is returned as an
+// error so that it stops only this diff rather than the whole process.
+func diffActor(before, after *types.Actor, opts []cmp.Option) (text string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return cmp.Diff(before, after, opts...), nil
+}
+
+// describeActor renders an actor present in only one of the roots compared.
+func describeActor(sign string, act *types.Actor) string {
+	return fmt.Sprintf("%s\t%s actor, head %s, balance %s\n", sign, builtin.ActorNameByCode(act.Code), act.Head, act.Balance)
+}
+
+// loadStateActors reads the actors of the state tree at `root`, keyed by
+// their address.
+func loadStateActors(ctx context.Context, store blockstore.Blockstore, root cid.Cid) (map[string]*types.Actor, error) {
+	actors, err := transformStateRoot(ctx, root, store, &transformConfig{})
+	if errors.Is(err, ErrEmptyStateRoot) {
+		return map[string]*types.Actor{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return actors.(map[string]*types.Actor), nil
+}
+
+// mustParseAddress parses an address key of a transformed state root, which
+// is always valid.
+func mustParseAddress(k string) addr.Address {
+	a, err := addr.NewFromString(k)
+	if err != nil {
+		panic(fmt.Sprintf("invalid state root key %q: %v", k, err))
+	}
+	return a
+}
+
+// actorLess orders actors by ID, placing any addresses which are not IDs
+// after them in the order of their string form.
+func actorLess(a, b addr.Address) bool {
+	aID, aErr := addr.IDFromAddress(a)
+	bID, bErr := addr.IDFromAddress(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		return aID < bID
+	case aErr == nil || bErr == nil:
+		return aErr == nil
+	}
+	return a.String() < b.String()
+}
+
+func sameActor(a, b *types.Actor) bool {
+	return a.Code.Equals(b.Code) && a.Head.Equals(b.Head) && a.Nonce == b.Nonce && a.Balance.Equals(b.Balance)
+}
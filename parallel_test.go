@@ -0,0 +1,127 @@
+package statediff_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	accountActor "github.com/filecoin-project/specs-actors/actors/builtin/account"
+	initActor "github.com/filecoin-project/specs-actors/actors/builtin/init"
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/statediff"
+	"github.com/filecoin-project/statediff/testutil"
+)
+
+func TestDiffParallelAddedAndRemovedActors(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+
+	initHead := mustPut(t, store, &initActor.State{
+		AddressMap:  mustHAMT(t, store, nil, 5),
+		NextID:      1003,
+		NetworkName: "test",
+	})
+	initAct := &types.Actor{Code: builtin.InitActorCodeID, Head: initHead, Balance: types.NewInt(0)}
+	account := func(id, balance uint64) *types.Actor {
+		head := mustPut(t, store, &accountActor.State{Address: idAddr(t, id)})
+		return &types.Actor{Code: builtin.AccountActorCodeID, Head: head, Balance: types.NewInt(balance)}
+	}
+
+	a := mustStateTree(t, store, map[addr.Address]*types.Actor{
+		builtin.InitActorAddr: initAct,
+		idAddr(t, 999):        account(999, 1),
+		idAddr(t, 1000):       account(1000, 1),
+		idAddr(t, 1001):       account(1001, 1),
+	})
+	b := mustStateTree(t, store, map[addr.Address]*types.Actor{
+		builtin.InitActorAddr: initAct,
+		idAddr(t, 999):        account(999, 2),
+		idAddr(t, 1000):       account(1000, 2),
+		idAddr(t, 1002):       account(1002, 1),
+	})
+
+	for _, tc := range []struct {
+		name string
+		opts []statediff.Option
+	}{
+		{"collapsed", nil},
+		{"expanded", []statediff.Option{statediff.ExpandActors}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := statediff.DiffParallel(ctx, store, a, b, 2, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			last := -1
+			for _, want := range []string{
+				"@@ t0999 @@\n",
+				"@@ t01000 @@\n",
+				"@@ t01001 @@\n-\tfil/1/account actor",
+				"@@ t01002 @@\n+\tfil/1/account actor",
+			} {
+				i := strings.Index(out, want)
+				if i < 0 {
+					t.Fatalf("diff lacks %q:\n%s", want, out)
+				}
+				if i < last {
+					t.Errorf("%q out of address order:\n%s", want, out)
+				}
+				last = i
+			}
+			if strings.Contains(out, "<InitActor>") {
+				t.Errorf("unchanged init actor reported:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestDiffParallelMissingState(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+
+	initHead := mustPut(t, store, &initActor.State{
+		AddressMap:  mustHAMT(t, store, nil, 5),
+		NextID:      101,
+		NetworkName: "test",
+	})
+	initAct := &types.Actor{Code: builtin.InitActorCodeID, Head: initHead, Balance: types.NewInt(0)}
+	head := mustPut(t, store, &accountActor.State{Address: idAddr(t, 100)})
+	missing := mustPut(t, testutil.NewMemStore(), &accountActor.State{Address: idAddr(t, 101)})
+
+	a := mustStateTree(t, store, map[addr.Address]*types.Actor{
+		builtin.InitActorAddr: initAct,
+		idAddr(t, 100):        {Code: builtin.AccountActorCodeID, Head: head, Balance: types.NewInt(0)},
+	})
+	b := mustStateTree(t, store, map[addr.Address]*types.Actor{
+		builtin.InitActorAddr: initAct,
+		idAddr(t, 100):        {Code: builtin.AccountActorCodeID, Head: missing, Balance: types.NewInt(0)},
+	})
+
+	if _, err := statediff.DiffParallel(ctx, store, a, b, 2, statediff.ExpandActors); err == nil {
+		t.Error("diffed an actor whose state is missing")
+	}
+}
+
+func TestDiffParallelCancelled(t *testing.T) {
+	store := testutil.NewMemStore()
+	initHead := mustPut(t, store, &initActor.State{
+		AddressMap:  mustHAMT(t, store, nil, 5),
+		NetworkName: "test",
+	})
+	root := func(balance uint64) cid.Cid {
+		return mustStateTree(t, store, map[addr.Address]*types.Actor{
+			builtin.InitActorAddr: {Code: builtin.InitActorCodeID, Head: initHead, Balance: types.NewInt(balance)},
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := statediff.DiffParallel(ctx, store, root(1), root(2), 2); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
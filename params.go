@@ -0,0 +1,58 @@
+package statediff
+
+import (
+	"bytes"
+
+	addr "github.com/filecoin-project/go-address"
+	abi "github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	multisigActor "github.com/filecoin-project/specs-actors/actors/builtin/multisig"
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+// ParamsDecoder interprets the serialized params of a message invoking
+// `method` on the actor at `to`. It returns false when it does not know
+// how to decode the message, in which case the params are left as bytes.
+type ParamsDecoder func(to addr.Address, method abi.MethodNum, params []byte) (interface{}, bool)
+
+// MultisigParamsDecoder decodes the params of transactions a multisig wallet
+// proposes to itself, such as adding or removing a signer. `self` is the
+// address of the multisig actor holding the transactions.
+func MultisigParamsDecoder(self addr.Address) ParamsDecoder {
+	return func(to addr.Address, method abi.MethodNum, params []byte) (interface{}, bool) {
+		if to != self {
+			return nil, false
+		}
+
+		var dest cbg.CBORUnmarshaler
+		switch method {
+		case builtin.MethodsMultisig.Propose:
+			dest = &multisigActor.ProposeParams{}
+		case builtin.MethodsMultisig.Approve:
+			fallthrough
+		case builtin.MethodsMultisig.Cancel:
+			dest = &multisigActor.TxnIDParams{}
+		case builtin.MethodsMultisig.AddSigner:
+			dest = &multisigActor.AddSignerParams{}
+		case builtin.MethodsMultisig.RemoveSigner:
+			dest = &multisigActor.RemoveSignerParams{}
+		case builtin.MethodsMultisig.SwapSigner:
+			dest = &multisigActor.SwapSignerParams{}
+		case builtin.MethodsMultisig.ChangeNumApprovalsThreshold:
+			dest = &multisigActor.ChangeNumApprovalsThresholdParams{}
+		default:
+			return nil, false
+		}
+		if err := dest.UnmarshalCBOR(bytes.NewBuffer(params)); err != nil {
+			return nil, false
+		}
+		return dest, true
+	}
+}
+
+// MultisigTransaction is a pending multisig transaction, along with its
+// params when a ParamsDecoder was able to interpret them.
+type MultisigTransaction struct {
+	multisigActor.Transaction
+	DecodedParams interface{} `json:",omitempty"`
+}
@@ -0,0 +1,59 @@
+package statediff_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	multisigActor "github.com/filecoin-project/specs-actors/actors/builtin/multisig"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	"github.com/filecoin-project/statediff"
+	"github.com/filecoin-project/statediff/testutil"
+)
+
+func TestTransformMultisigPendingParams(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+	wallet := idAddr(t, 1000)
+
+	var params bytes.Buffer
+	if err := (&multisigActor.AddSignerParams{Signer: idAddr(t, 1001)}).MarshalCBOR(&params); err != nil {
+		t.Fatal(err)
+	}
+	root := mustHAMT(t, store, map[string]cbg.CBORMarshaler{
+		multisigActor.TxnID(1).Key(): &multisigActor.Transaction{
+			To:       wallet,
+			Value:    *tokens(0),
+			Method:   builtin.MethodsMultisig.AddSigner,
+			Params:   params.Bytes(),
+			Approved: []addr.Address{idAddr(t, 1002)},
+		},
+	}, 5)
+
+	out, err := statediff.TransformType(ctx, root, store, statediff.MultisigActorPending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, ok := out.(map[int64]multisigActor.Transaction)
+	if !ok || len(plain) != 1 {
+		t.Fatalf("pending transactions transformed as %#v", out)
+	}
+
+	out, err = statediff.TransformType(ctx, root, store, statediff.MultisigActorPending, statediff.DecodeParams(statediff.MultisigParamsDecoder(wallet)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, ok := out.(map[int64]statediff.MultisigTransaction)
+	if !ok || len(decoded) != 1 {
+		t.Fatalf("decoded pending transactions transformed as %#v", out)
+	}
+	for _, txn := range decoded {
+		signer, ok := txn.DecodedParams.(*multisigActor.AddSignerParams)
+		if !ok || signer.Signer != idAddr(t, 1001) {
+			t.Errorf("params decoded as %#v", txn.DecodedParams)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package statediff
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+
+	cbor "github.com/ipfs/go-ipld-cbor"
+)
+
+// The compact diff is a cbor list of operations, each a list of the form
+// [op, path, value] where op is "s" to set the value at path, or "d" to delete
+// it. Paths are lists of map keys and list indexes, as strings. Operations
+// apply to the JSON form of transformed values, as rendered by MarshalJSON.
+const (
+	patchSet    = "s"
+	patchDelete = "d"
+)
+
+// EncodeDiff produces a compact binary diff from transformed value `old` to
+// `new`, which ApplyDiff can use to reconstruct `new` from `old`. This allows
+// per-epoch changes to be stored as deltas rather than whole states.
+func EncodeDiff(old, new interface{}) ([]byte, error) {
+	a, err := genericForm(old)
+	if err != nil {
+		return nil, err
+	}
+	b, err := genericForm(new)
+	if err != nil {
+		return nil, err
+	}
+	ops := make([]interface{}, 0)
+	diffGeneric(a, b, []interface{}{}, &ops)
+	return cbor.DumpObject(ops)
+}
+
+// ApplyDiff reconstructs a transformed value from the value before the
+// change, `old`, and the diff produced by EncodeDiff. The result has the go
+// type of `old`, such as `marketActor.DealProposal`, so it must be a type
+// which `encoding/json` can read back from its MarshalJSON rendering.
+func ApplyDiff(old interface{}, diff []byte) (interface{}, error) {
+	root, err := genericForm(old)
+	if err != nil {
+		return nil, err
+	}
+	var ops []interface{}
+	if err := cbor.DecodeInto(diff, &ops); err != nil {
+		return nil, err
+	}
+	for i, o := range ops {
+		op, ok := o.([]interface{})
+		if !ok || len(op) != 3 {
+			return nil, fmt.Errorf("malformed diff operation %d", i)
+		}
+		path, ok := op[1].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("malformed path in diff operation %d", i)
+		}
+		if root, err = applyOp(root, op[0], path, op[2]); err != nil {
+			return nil, fmt.Errorf("diff operation %d: %w", i, err)
+		}
+	}
+	return fromGenericForm(root, reflect.TypeOf(old))
+}
+
+// fromGenericForm decodes a value in the form produced by genericForm back
+// into a value of go type `t`.
+func fromGenericForm(v interface{}, t reflect.Type) (interface{}, error) {
+	if t == nil {
+		return v, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dest := reflect.New(t)
+	if err := json.Unmarshal(data, dest.Interface()); err != nil {
+		return nil, fmt.Errorf("cannot decode diff result as %s: %w", t, err)
+	}
+	return dest.Elem().Interface(), nil
+}
+
+func diffGeneric(a, b interface{}, path []interface{}, ops *[]interface{}) {
+	am, aIsMap := a.(map[string]interface{})
+	bm, bIsMap := b.(map[string]interface{})
+	if aIsMap && bIsMap {
+		keys := make([]string, 0, len(am)+len(bm))
+		for k := range am {
+			keys = append(keys, k)
+		}
+		for k := range bm {
+			if _, ok := am[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			av, inA := am[k]
+			bv, inB := bm[k]
+			sub := append(append([]interface{}{}, path...), k)
+			switch {
+			case !inB:
+				*ops = append(*ops, []interface{}{patchDelete, sub, nil})
+			case !inA:
+				*ops = append(*ops, []interface{}{patchSet, sub, bv})
+			default:
+				diffGeneric(av, bv, sub, ops)
+			}
+		}
+		return
+	}
+
+	al, aIsList := a.([]interface{})
+	bl, bIsList := b.([]interface{})
+	if aIsList && bIsList && len(al) == len(bl) {
+		for i := range al {
+			diffGeneric(al[i], bl[i], append(append([]interface{}{}, path...), strconv.Itoa(i)), ops)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		*ops = append(*ops, []interface{}{patchSet, path, b})
+	}
+}
+
+// applyOp performs a single diff operation on `node`, returning the result.
+func applyOp(node interface{}, op interface{}, path []interface{}, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		if op == patchDelete {
+			return nil, nil
+		}
+		return value, nil
+	}
+	key, ok := path[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("non-string path element %v", path[0])
+	}
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 && op == patchDelete {
+			delete(n, key)
+			return n, nil
+		}
+		child, err := applyOp(n[key], op, path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		n[key] = child
+		return n, nil
+	case []interface{}:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(n) {
+			return nil, fmt.Errorf("invalid list index %s", key)
+		}
+		child, err := applyOp(n[i], op, path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		n[i] = child
+		return n, nil
+	default:
+		return nil, fmt.Errorf("cannot descend into %T at %s", node, key)
+	}
+}
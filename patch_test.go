@@ -0,0 +1,43 @@
+package statediff_test
+
+import (
+	"reflect"
+	"testing"
+
+	abi "github.com/filecoin-project/go-state-types/abi"
+	marketActor "github.com/filecoin-project/specs-actors/actors/builtin/market"
+
+	"github.com/filecoin-project/statediff"
+)
+
+func TestApplyDiff(t *testing.T) {
+	first := *testProposal(t, 100, 1000, 10)
+	second := *testProposal(t, 101, 1000, 20)
+	changed := first
+	changed.Label = "changed"
+	changed.VerifiedDeal = true
+	changed.ClientCollateral = abi.NewTokenAmount(1 << 62)
+
+	for _, tc := range []struct {
+		name     string
+		old, new interface{}
+	}{
+		{"unchanged", first, first},
+		{"fields", first, changed},
+		{"entries", map[int64]marketActor.DealProposal{0: first, 1: second}, map[int64]marketActor.DealProposal{0: changed, 2: second}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			diff, err := statediff.EncodeDiff(tc.old, tc.new)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := statediff.ApplyDiff(tc.old, diff)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.new) {
+				t.Errorf("reconstructed %#v, want %#v", got, tc.new)
+			}
+		})
+	}
+}
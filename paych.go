@@ -0,0 +1,38 @@
+package statediff
+
+import (
+	"context"
+
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-ipfs-blockstore"
+	cbor "github.com/ipfs/go-ipld-cbor"
+
+	paychActor "github.com/filecoin-project/specs-actors/actors/builtin/paych"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+)
+
+// PaychTotalRedeemed sums the amount redeemed across all lanes of a payment
+// channel, given the cid of the channel's state.
+func PaychTotalRedeemed(ctx context.Context, c cid.Cid, store blockstore.Blockstore) (big.Int, error) {
+	cborStore := cbor.NewCborStore(store)
+
+	state := paychActor.State{}
+	if err := cborStore.Get(ctx, c, &state); err != nil {
+		return big.Zero(), err
+	}
+	lanes, err := adt.AsArray(adt.WrapStore(ctx, cborStore), state.LaneStates)
+	if err != nil {
+		return big.Zero(), err
+	}
+
+	total := big.Zero()
+	var lane paychActor.LaneState
+	if err := lanes.ForEach(&lane, func(int64) error {
+		total = big.Add(total, lane.Redeemed)
+		return nil
+	}); err != nil {
+		return big.Zero(), err
+	}
+	return total, nil
+}
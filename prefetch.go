@@ -0,0 +1,88 @@
+package statediff
+
+import (
+	"context"
+	"sync"
+
+	amt "github.com/filecoin-project/go-amt-ipld/v2"
+	"github.com/ipfs/go-cid"
+	hamt "github.com/ipfs/go-hamt-ipld"
+	cbor "github.com/ipfs/go-ipld-cbor"
+)
+
+// The prefetchers load the interior blocks of a collection ahead of its
+// iteration, fetching each level of the tree concurrently. This warms caching
+// blockstores, like the one returned by StoreFor, so that the ordered walk
+// which follows does not pay the latency of each block in turn. Failures are
+// ignored here, and left to be reported by that walk.
+
+func prefetchHAMT(ctx context.Context, store cbor.IpldStore, root *hamt.Node, workers int, opts ...hamt.Option) {
+	frontier := hamtLinks(root)
+	for len(frontier) > 0 && ctx.Err() == nil {
+		frontier = fetchLevel(workers, frontier, func(c cid.Cid) []cid.Cid {
+			node, err := hamt.LoadNode(ctx, store, c, opts...)
+			if err != nil {
+				return nil
+			}
+			return hamtLinks(node)
+		})
+	}
+}
+
+func hamtLinks(n *hamt.Node) []cid.Cid {
+	links := make([]cid.Cid, 0, len(n.Pointers))
+	for _, p := range n.Pointers {
+		if p.Link.Defined() {
+			links = append(links, p.Link)
+		}
+	}
+	return links
+}
+
+func prefetchAMT(ctx context.Context, store cbor.IpldStore, c cid.Cid, workers int) {
+	root, err := amt.LoadAMT(ctx, store, c)
+	if err != nil {
+		return
+	}
+	frontier := root.Node.Links
+	// Nodes at height 0 are leaves, holding values rather than links.
+	for height := root.Height; height > 0 && ctx.Err() == nil; height-- {
+		frontier = fetchLevel(workers, frontier, func(c cid.Cid) []cid.Cid {
+			node := amt.Node{}
+			if err := store.Get(ctx, c, &node); err != nil {
+				return nil
+			}
+			return node.Links
+		})
+	}
+}
+
+// fetchLevel applies `fetch` to each cid using a pool of `workers`, returning
+// the concatenation of the cids they discover.
+func fetchLevel(workers int, cids []cid.Cid, fetch func(cid.Cid) []cid.Cid) []cid.Cid {
+	if workers < 1 {
+		workers = 1
+	}
+	queue := make(chan cid.Cid)
+	var lk sync.Mutex
+	var wg sync.WaitGroup
+	next := make([]cid.Cid, 0)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range queue {
+				found := fetch(c)
+				lk.Lock()
+				next = append(next, found...)
+				lk.Unlock()
+			}
+		}()
+	}
+	for _, c := range cids {
+		queue <- c
+	}
+	close(queue)
+	wg.Wait()
+	return next
+}
@@ -0,0 +1,79 @@
+package statediff
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+// The registry of single block types starts with the builtin simpleTypes,
+// and may be extended by applications with RegisterType. Once frozen, it can
+// no longer change, and is read without locking.
+var (
+	registryLk     sync.RWMutex
+	registryFrozen int32
+)
+
+// ErrRegistryFrozen is returned when registering a type after Freeze.
+var ErrRegistryFrozen = errors.New("type registry is frozen")
+
+var cborUnmarshalerType = reflect.TypeOf((*cbg.CBORUnmarshaler)(nil)).Elem()
+
+// RegisterType adds a LotusType which is held within a single block, and is
+// decoded as the go type of `prototype`, such as `myActor.State{}`. A pointer
+// to the type must implement cbg.CBORUnmarshaler. Registered types can be
+// used with Transform and as the values of TransformAMT and TransformHAMT.
+func RegisterType(as LotusType, prototype interface{}) error {
+	t := reflect.TypeOf(prototype)
+	if t == nil || !reflect.PtrTo(t).Implements(cborUnmarshalerType) {
+		return fmt.Errorf("%T cannot be decoded from cbor", prototype)
+	}
+
+	registryLk.Lock()
+	defer registryLk.Unlock()
+	if registryFrozen != 0 {
+		return ErrRegistryFrozen
+	}
+	simpleTypes[as] = t
+	return nil
+}
+
+// Freeze prevents further registration of types, so that transforms can look
+// types up without taking a lock. Applications should register their types
+// at startup and then freeze the registry.
+func Freeze() {
+	registryLk.Lock()
+	defer registryLk.Unlock()
+	atomic.StoreInt32(&registryFrozen, 1)
+}
+
+// lookupType finds the go type a single block LotusType is decoded as.
+func lookupType(as LotusType) (reflect.Type, bool) {
+	if atomic.LoadInt32(&registryFrozen) != 0 {
+		t, ok := simpleTypes[as]
+		return t, ok
+	}
+	registryLk.RLock()
+	defer registryLk.RUnlock()
+	t, ok := simpleTypes[as]
+	return t, ok
+}
+
+// registeredTypes lists the single block LotusTypes, in sorted order.
+func registeredTypes() []LotusType {
+	if atomic.LoadInt32(&registryFrozen) == 0 {
+		registryLk.RLock()
+		defer registryLk.RUnlock()
+	}
+	types := make([]LotusType, 0, len(simpleTypes))
+	for as := range simpleTypes {
+		types = append(types, as)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
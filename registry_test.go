@@ -0,0 +1,114 @@
+package statediff_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	accountActor "github.com/filecoin-project/specs-actors/actors/builtin/account"
+	storageMinerActor "github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	"github.com/filecoin-project/statediff"
+	"github.com/filecoin-project/statediff/testutil"
+)
+
+// TestRegisterType freezes the registry, so it must be the only test to
+// register types.
+func TestRegisterType(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+	head := mustPut(t, store, &accountActor.State{Address: idAddr(t, 100)})
+	var buf bytes.Buffer
+	if err := (*cbg.CborCid)(&head).MarshalCBOR(&buf); err != nil {
+		t.Fatal(err)
+	}
+	link := blocks.NewBlock(buf.Bytes())
+	if err := store.Put(link); err != nil {
+		t.Fatal(err)
+	}
+	const linkType = statediff.LotusType("test.Link")
+
+	if err := statediff.RegisterType("test.Invalid", 5); err == nil {
+		t.Error("registered a type which cannot be decoded from cbor")
+	}
+
+	// Transforms may run while types are registered.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if _, err := statediff.TransformType(ctx, head, store, statediff.AccountActorState); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	if err := statediff.RegisterType(linkType, cbg.CborCid{}); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	statediff.Freeze()
+	if err := statediff.RegisterType("test.Other", cbg.CborCid{}); !errors.Is(err, statediff.ErrRegistryFrozen) {
+		t.Errorf("expected ErrRegistryFrozen, got %v", err)
+	}
+	out, err := statediff.TransformType(ctx, link.Cid(), store, linkType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := out.(cbg.CborCid); !ok || !head.Equals(cid.Cid(c)) {
+		t.Errorf("registered type decoded as %#v", out)
+	}
+}
+
+func TestProbeType(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+	head := mustPut(t, store, &accountActor.State{Address: idAddr(t, 100)})
+	info := mustPut(t, store, &storageMinerActor.MinerInfo{
+		Owner:      idAddr(t, 100),
+		Worker:     idAddr(t, 101),
+		PeerId:     []byte("peer"),
+		SectorSize: 2048,
+	})
+	garbage := blocks.NewBlock([]byte("not cbor"))
+	if err := store.Put(garbage); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		c    cid.Cid
+		want statediff.LotusType
+	}{
+		{"account", head, statediff.AccountActorState},
+		{"miner info", info, statediff.StorageMinerActorInfo},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			types, err := statediff.ProbeType(ctx, tc.c, store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(types) != 1 || types[0] != tc.want {
+				t.Errorf("probed as %v, want %s", types, tc.want)
+			}
+		})
+	}
+
+	if types, err := statediff.ProbeType(ctx, garbage.Cid(), store); err != nil || len(types) != 0 {
+		t.Errorf("garbage probed as %v: %v", types, err)
+	}
+	missing := mustPut(t, testutil.NewMemStore(), &accountActor.State{Address: idAddr(t, 101)})
+	var notFound *statediff.BlockNotFoundError
+	if _, err := statediff.ProbeType(ctx, missing, store); !errors.As(err, &notFound) {
+		t.Errorf("expected a BlockNotFoundError, got %v", err)
+	}
+}
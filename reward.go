@@ -0,0 +1,45 @@
+package statediff
+
+import (
+	"context"
+
+	abi "github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-ipfs-blockstore"
+	cbor "github.com/ipfs/go-ipld-cbor"
+
+	rewardActor "github.com/filecoin-project/specs-actors/actors/builtin/reward"
+)
+
+// RewardSupply holds the reward actor fields used to compute circulating
+// supply and the progress of the network against its baseline.
+type RewardSupply struct {
+	Epoch abi.ChainEpoch
+	// TotalStoragePowerReward is the total FIL awarded to block producers,
+	// recorded by the v0 reward actor as `TotalMined`.
+	TotalStoragePowerReward abi.TokenAmount
+	ThisEpochReward         abi.TokenAmount
+	CumsumBaseline          big.Int
+	CumsumRealized          big.Int
+	EffectiveNetworkTime    abi.ChainEpoch
+	EffectiveBaselinePower  abi.StoragePower
+}
+
+// RewardActorSupply extracts the supply inputs from the reward actor state at
+// `c`, the `Head` of the reward actor.
+func RewardActorSupply(ctx context.Context, c cid.Cid, store blockstore.Blockstore) (*RewardSupply, error) {
+	state := rewardActor.State{}
+	if err := cbor.NewCborStore(store).Get(ctx, c, &state); err != nil {
+		return nil, err
+	}
+	return &RewardSupply{
+		Epoch:                   state.Epoch,
+		TotalStoragePowerReward: state.TotalMined,
+		ThisEpochReward:         state.ThisEpochReward,
+		CumsumBaseline:          state.CumsumBaseline,
+		CumsumRealized:          state.CumsumRealized,
+		EffectiveNetworkTime:    state.EffectiveNetworkTime,
+		EffectiveBaselinePower:  state.EffectiveBaselinePower,
+	}, nil
+}
@@ -2,13 +2,18 @@ package statediff
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/lib/blockstore"
 	blocks "github.com/ipfs/go-block-format"
 	"github.com/ipfs/go-cid"
 	ds "github.com/ipfs/go-datastore"
+	format "github.com/ipfs/go-ipld-format"
 )
 
 type proxyingBlockstore struct {
@@ -57,3 +62,121 @@ func StoreFor(ctx context.Context, client api.FullNode) blockstore.Blockstore {
 	}
 	return bs
 }
+
+// BlockNotFoundError indicates that a block needed to interpret state is not
+// present in the blockstore. The error reported by the blockstore is
+// preserved and available through Unwrap, and the error also matches
+// format.ErrNotFound, so that `errors.Is(err, format.ErrNotFound)` detects a
+// missing block whichever form the blockstore reported it in.
+type BlockNotFoundError struct {
+	Cid cid.Cid
+	Err error
+}
+
+func (e *BlockNotFoundError) Error() string {
+	return fmt.Sprintf("block %s not found: %v", e.Cid, e.Err)
+}
+
+func (e *BlockNotFoundError) Unwrap() error {
+	return e.Err
+}
+
+func (e *BlockNotFoundError) Is(target error) bool {
+	return target == format.ErrNotFound
+}
+
+// isNotFound recognizes the different forms blockstores use to report
+// a missing block.
+func isNotFound(err error) bool {
+	return errors.Is(err, blockstore.ErrNotFound) ||
+		errors.Is(err, format.ErrNotFound) ||
+		errors.Is(err, ds.ErrNotFound)
+}
+
+// classifyingBlockstore wraps errors for missing blocks as BlockNotFoundError,
+// so that callers can distinguish them from other failures of the store.
+type classifyingBlockstore struct {
+	blockstore.Blockstore
+}
+
+func (cb *classifyingBlockstore) Get(c cid.Cid) (blocks.Block, error) {
+	block, err := cb.Blockstore.Get(c)
+	if err != nil && isNotFound(err) {
+		return nil, &BlockNotFoundError{Cid: c, Err: err}
+	}
+	return block, err
+}
+
+// countingBlockstore tallies the blocks read through it into a Metrics.
+type countingBlockstore struct {
+	blockstore.Blockstore
+	metrics *Metrics
+}
+
+func (cb *countingBlockstore) Get(c cid.Cid) (blocks.Block, error) {
+	block, err := cb.Blockstore.Get(c)
+	if err == nil {
+		atomic.AddUint64(&cb.metrics.Blocks, 1)
+		atomic.AddUint64(&cb.metrics.Bytes, uint64(len(block.RawData())))
+	}
+	return block, err
+}
+
+// limitingBlockstore fails reads with ErrTooLarge once the blocks read
+// through it exceed a limit.
+type limitingBlockstore struct {
+	blockstore.Blockstore
+	limit uint64
+	read  uint64
+}
+
+func (lb *limitingBlockstore) Get(c cid.Cid) (blocks.Block, error) {
+	if atomic.LoadUint64(&lb.read) > lb.limit {
+		return nil, fmt.Errorf("%w: limit is %d bytes", ErrTooLarge, lb.limit)
+	}
+	block, err := lb.Blockstore.Get(c)
+	if err == nil && atomic.AddUint64(&lb.read, uint64(len(block.RawData()))) > lb.limit {
+		return nil, fmt.Errorf("%w: limit is %d bytes", ErrTooLarge, lb.limit)
+	}
+	return block, err
+}
+
+// BlockTimeoutError indicates that the blockstore did not return a block within
+// the time allowed by BlockTimeout.
+type BlockTimeoutError struct {
+	Cid     cid.Cid
+	Timeout time.Duration
+}
+
+func (e *BlockTimeoutError) Error() string {
+	return fmt.Sprintf("block %s not read within %s", e.Cid, e.Timeout)
+}
+
+// timeoutBlockstore abandons reads which take longer than a timeout. The
+// blockstore interface cannot be cancelled, so an abandoned read continues
+// in the background until the underlying store returns.
+type timeoutBlockstore struct {
+	blockstore.Blockstore
+	timeout time.Duration
+}
+
+func (tb *timeoutBlockstore) Get(c cid.Cid) (blocks.Block, error) {
+	type result struct {
+		block blocks.Block
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		block, err := tb.Blockstore.Get(c)
+		done <- result{block, err}
+	}()
+
+	timer := time.NewTimer(tb.timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.block, r.err
+	case <-timer.C:
+		return nil, &BlockTimeoutError{Cid: c, Timeout: tb.timeout}
+	}
+}
@@ -0,0 +1,94 @@
+package statediff_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	accountActor "github.com/filecoin-project/specs-actors/actors/builtin/account"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-ipfs-blockstore"
+	format "github.com/ipfs/go-ipld-format"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	"github.com/filecoin-project/statediff"
+	"github.com/filecoin-project/statediff/testutil"
+)
+
+func TestMissingBlockIsNotFound(t *testing.T) {
+	ctx := context.Background()
+	missing := mustPut(t, testutil.NewMemStore(), &accountActor.State{Address: idAddr(t, 100)})
+
+	_, err := statediff.TransformType(ctx, missing, testutil.NewMemStore(), statediff.AccountActorState)
+	var notFound *statediff.BlockNotFoundError
+	if !errors.As(err, &notFound) {
+		t.Fatalf("expected a BlockNotFoundError, got %v", err)
+	}
+	if !notFound.Cid.Equals(missing) {
+		t.Errorf("error names block %s, want %s", notFound.Cid, missing)
+	}
+	if !errors.Is(err, format.ErrNotFound) {
+		t.Error("missing block does not match format.ErrNotFound")
+	}
+	if notFound.Unwrap() == nil {
+		t.Error("error reported by the blockstore was not preserved")
+	}
+}
+
+// slowBlockstore delays every read by a fixed duration.
+type slowBlockstore struct {
+	blockstore.Blockstore
+	delay time.Duration
+}
+
+func (sb *slowBlockstore) Get(c cid.Cid) (blocks.Block, error) {
+	time.Sleep(sb.delay)
+	return sb.Blockstore.Get(c)
+}
+
+func TestBlockTimeout(t *testing.T) {
+	ctx := context.Background()
+	mem := testutil.NewMemStore()
+	head := mustPut(t, mem, &accountActor.State{Address: idAddr(t, 100)})
+	store := &slowBlockstore{mem, 200 * time.Millisecond}
+
+	_, err := statediff.TransformType(ctx, head, store, statediff.AccountActorState, statediff.BlockTimeout(10*time.Millisecond))
+	var timeout *statediff.BlockTimeoutError
+	if !errors.As(err, &timeout) {
+		t.Fatalf("expected a BlockTimeoutError, got %v", err)
+	}
+	if !timeout.Cid.Equals(head) || timeout.Timeout != 10*time.Millisecond {
+		t.Errorf("timeout reported as %v", timeout)
+	}
+
+	if _, err := statediff.TransformType(ctx, head, store, statediff.AccountActorState, statediff.BlockTimeout(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMaxMemory(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+	entries := make(map[uint64]cbg.CBORMarshaler)
+	for i := uint64(0); i < 100; i++ {
+		entries[i*50] = &accountActor.State{Address: idAddr(t, 1000+i)}
+	}
+	root := mustAMT(t, store, entries)
+
+	var metrics statediff.Metrics
+	if _, err := statediff.TransformAMT(ctx, root, store, statediff.AccountActorState, statediff.CollectMetrics(&metrics)); err != nil {
+		t.Fatal(err)
+	}
+	if metrics.Blocks < 2 {
+		t.Fatalf("AMT held in %d blocks, expected several", metrics.Blocks)
+	}
+
+	if _, err := statediff.TransformAMT(ctx, root, store, statediff.AccountActorState, statediff.MaxMemory(metrics.Bytes-1)); !errors.Is(err, statediff.ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+	if _, err := statediff.TransformAMT(ctx, root, store, statediff.AccountActorState, statediff.MaxMemory(metrics.Bytes)); err != nil {
+		t.Errorf("transform within its limit failed: %v", err)
+	}
+}
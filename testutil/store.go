@@ -0,0 +1,78 @@
+// Package testutil helps assemble state for exercising statediff, by building
+// blockstores out of blocks and go values.
+package testutil
+
+import (
+	"context"
+
+	addr "github.com/filecoin-project/go-address"
+	amt "github.com/filecoin-project/go-amt-ipld/v2"
+	"github.com/filecoin-project/lotus/chain/types"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	hamt "github.com/ipfs/go-hamt-ipld"
+	"github.com/ipfs/go-ipfs-blockstore"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+// NewMemStore creates an in-memory blockstore holding the given blocks.
+func NewMemStore(blks ...blocks.Block) blockstore.Blockstore {
+	store := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	if err := store.PutMany(blks); err != nil {
+		// The map datastore does not fail writes.
+		panic(err)
+	}
+	return store
+}
+
+// PutObject cbor encodes `obj` into the store, returning its cid. Collections
+// can be built by flushing adt or hamt structures over
+// `cbor.NewCborStore(store)`, and linking to the cids they return.
+func PutObject(store blockstore.Blockstore, obj cbg.CBORMarshaler) (cid.Cid, error) {
+	return cbor.NewCborStore(store).Put(context.Background(), obj)
+}
+
+// PutHAMT builds a HAMT of the given bit width holding `entries`, keyed by
+// their raw key bytes, returning its root cid. Keys are hashed with sha256,
+// as they are by the actors.
+func PutHAMT(store blockstore.Blockstore, entries map[string]cbg.CBORMarshaler, bitwidth int) (cid.Cid, error) {
+	ctx := context.Background()
+	cborStore := cbor.NewCborStore(store)
+	opts := append(append([]hamt.Option{}, adt.HamtOptions...), hamt.UseTreeBitWidth(bitwidth))
+	node := hamt.NewNode(cborStore, opts...)
+	for k, v := range entries {
+		if err := node.Set(ctx, k, v); err != nil {
+			return cid.Undef, err
+		}
+	}
+	if err := node.Flush(ctx); err != nil {
+		return cid.Undef, err
+	}
+	return cborStore.Put(ctx, node)
+}
+
+// PutStateTree builds a state root holding `actors`, returning its cid.
+func PutStateTree(store blockstore.Blockstore, actors map[addr.Address]*types.Actor) (cid.Cid, error) {
+	entries := make(map[string]cbg.CBORMarshaler, len(actors))
+	for a, act := range actors {
+		entries[string(a.Bytes())] = act
+	}
+	return PutHAMT(store, entries, 5)
+}
+
+// PutAMT builds an AMT holding `entries` at their indexes, returning its
+// root cid.
+func PutAMT(store blockstore.Blockstore, entries map[uint64]cbg.CBORMarshaler) (cid.Cid, error) {
+	ctx := context.Background()
+	root := amt.NewAMT(cbor.NewCborStore(store))
+	for i, v := range entries {
+		if err := root.Set(ctx, i, v); err != nil {
+			return cid.Undef, err
+		}
+	}
+	return root.Flush(ctx)
+}
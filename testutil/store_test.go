@@ -0,0 +1,47 @@
+package testutil_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	blocks "github.com/ipfs/go-block-format"
+	cbor "github.com/ipfs/go-ipld-cbor"
+
+	"github.com/filecoin-project/statediff/testutil"
+)
+
+func TestNewMemStoreHoldsBlocks(t *testing.T) {
+	blk := blocks.NewBlock([]byte("statediff"))
+	store := testutil.NewMemStore(blk)
+	got, err := store.Get(blk.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got.RawData()) != "statediff" {
+		t.Errorf("block read back as %q", got.RawData())
+	}
+}
+
+func TestPutObjectRoundTrip(t *testing.T) {
+	store := testutil.NewMemStore()
+	act := &types.Actor{
+		Code:    builtin.AccountActorCodeID,
+		Head:    builtin.AccountActorCodeID,
+		Nonce:   3,
+		Balance: types.NewInt(42),
+	}
+	c, err := testutil.PutObject(store, act)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got types.Actor
+	if err := cbor.NewCborStore(store).Get(context.Background(), c, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Code != act.Code || got.Nonce != act.Nonce || !got.Balance.Equals(act.Balance) {
+		t.Errorf("actor read back as %+v", got)
+	}
+}
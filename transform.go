@@ -3,12 +3,17 @@ package statediff
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
 	"regexp"
+	"sync/atomic"
+	"time"
 
 	addr "github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-bitfield"
 	abi "github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
 	"github.com/ipfs/go-cid"
 	hamt "github.com/ipfs/go-hamt-ipld"
 	"github.com/ipfs/go-ipfs-blockstore"
@@ -35,6 +40,8 @@ type LotusType string
 const (
 	LotusTypeTipset                            LotusType = "tipset"
 	LotusTypeStateroot                         LotusType = "stateRoot"
+	LotusTypeMessageReceipts                   LotusType = "tipset.ParentMessageReceipts"
+	LotusTypeMessages                          LotusType = "tipset.Messages"
 	AccountActorState                          LotusType = "accountActor"
 	CronActorState                             LotusType = "cronActor"
 	InitActorState                             LotusType = "initActor"
@@ -75,55 +82,327 @@ const (
 var simplifyingRe = regexp.MustCompile(`\[\d+\]`)
 var simplifyingRe2 = regexp.MustCompile(`\.\d+\.`)
 
+// ResolveType simplifies a user provided path, like
+// `storageMinerActor.Deadlines.Due[3].Partitions`, to the LotusType it refers to.
+func ResolveType(as string) LotusType {
+	return LotusType(simplifyingRe2.ReplaceAll(simplifyingRe.ReplaceAll([]byte(as), []byte("")), []byte(".")))
+}
+
+type transformConfig struct {
+	ParamsDecoder      ParamsDecoder
+	MaxDepth           int
+	Prefetch           int
+	ActorRoot          cid.Cid
+	ActorAddress       addr.Address
+	Validate           bool
+	Metrics            *Metrics
+	Page               *Page
+	MaxMemory          uint64
+	Lenient            bool
+	BitWidth           int
+	RejectTrailingData bool
+	VerifyRoot         bool
+	BlockTimeout       time.Duration
+
+	depth int
+}
+
+// TransformOption customizes how data is interpreted by Transform.
+type TransformOption func(c *transformConfig)
+
+// DecodeParams interprets the params of pending multisig transactions with
+// the provided decoder, rather than leaving them as opaque bytes. Pending
+// transactions are then transformed as a map[int64]MultisigTransaction,
+// rather than a map[int64]multisigActor.Transaction.
+func DecodeParams(d ParamsDecoder) TransformOption {
+	return func(c *transformConfig) {
+		c.ParamsDecoder = d
+	}
+}
+
+// MaxDepth limits how many levels of collections a transform may read,
+// counting the transformed collection itself as the first level. A multimap,
+// whose arrays nest one level below it, needs a depth of at least 2, while
+// MaxDepth(1) rejects any nested collection. Exceeding it results in
+// ErrMaxDepth.
+func MaxDepth(depth int) TransformOption {
+	return func(c *transformConfig) {
+		c.MaxDepth = depth
+	}
+}
+
+// Prefetch concurrently loads the blocks of large collections, using the
+// given number of workers, before walking them. This reduces the time taken
+// to transform the state root and miner sectors over a high latency but
+// caching blockstore, such as one proxying a lotus node.
+func Prefetch(workers int) TransformOption {
+	return func(c *transformConfig) {
+		c.Prefetch = workers
+	}
+}
+
+// CheckActorCode verifies, before decoding, that the cid being transformed is
+// the head of the actor at `address` within state root `root`, and that the
+// code of that actor, as recorded in its state root entry, matches the
+// LotusType requested. A mismatched code results in an *ActorCodeMismatchError.
+// The check applies to the head state of the builtin actors, the types such as
+// `storageMinerActor` and `initActor`. Other types, such as
+// `storageMinerActor.Info`, are not the head of an actor, and transforming
+// them with this option fails with ErrNotActorState, as does transforming a
+// collection with TransformAMT or TransformHAMT.
+func CheckActorCode(root cid.Cid, address addr.Address) TransformOption {
+	return func(c *transformConfig) {
+		c.ActorRoot = root
+		c.ActorAddress = address
+	}
+}
+
+// Validate checks the consistency of decoded state, reporting violations as a
+// typed error alongside the decoded value. The init actor's address map is
+// checked to hold no ID at or above its NextID, and payment channel lanes to
+// hold no negative or unexplained redemptions.
+func Validate(c *transformConfig) {
+	c.Validate = true
+}
+
+// Metrics tallies the work done by transforms. Blocks and Bytes count the
+// blocks read from the store, and Entries the values decoded: the number of
+// entries of a collection, or 1 for state held in a single block.
+type Metrics struct {
+	Blocks  uint64
+	Bytes   uint64
+	Entries uint64
+}
+
+// CollectMetrics accumulates the work done by a transform into `m`, which
+// may be shared between transforms to measure them in aggregate.
+func CollectMetrics(m *Metrics) TransformOption {
+	return func(c *transformConfig) {
+		c.Metrics = m
+	}
+}
+
+// wrapStore prepares a store for use by a transform with this config.
+func (c *transformConfig) wrapStore(store blockstore.Blockstore) blockstore.Blockstore {
+	if c.BlockTimeout > 0 {
+		store = &timeoutBlockstore{store, c.BlockTimeout}
+	}
+	store = &classifyingBlockstore{store}
+	if c.Metrics != nil {
+		store = &countingBlockstore{store, c.Metrics}
+	}
+	if c.MaxMemory > 0 {
+		store = &limitingBlockstore{Blockstore: store, limit: c.MaxMemory}
+	}
+	return store
+}
+
+// configuredStore wraps `store` for the helpers which read state directly
+// rather than through TransformType, so that the options which guard and
+// measure reads apply to them too.
+func configuredStore(store blockstore.Blockstore, opts []TransformOption) blockstore.Blockstore {
+	conf := transformConfig{}
+	for _, o := range opts {
+		o(&conf)
+	}
+	return conf.wrapStore(store)
+}
+
+// countEntries records the entries of a transformed value in the metrics.
+func (c *transformConfig) countEntries(v interface{}) {
+	if c.Metrics == nil {
+		return
+	}
+	n := uint64(1)
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Map {
+		n = uint64(rv.Len())
+	}
+	atomic.AddUint64(&c.Metrics.Entries, n)
+}
+
+// actorCodes are the code cids of the actors with head state of each LotusType.
+var actorCodes = map[LotusType]cid.Cid{
+	AccountActorState:          builtin.AccountActorCodeID,
+	CronActorState:             builtin.CronActorCodeID,
+	InitActorState:             builtin.InitActorCodeID,
+	MarketActorState:           builtin.StorageMarketActorCodeID,
+	MultisigActorState:         builtin.MultisigActorCodeID,
+	StorageMinerActorState:     builtin.StorageMinerActorCodeID,
+	StoragePowerActorState:     builtin.StoragePowerActorCodeID,
+	RewardActorState:           builtin.RewardActorCodeID,
+	VerifiedRegistryActorState: builtin.VerifiedRegistryActorCodeID,
+	PaymentChannelActorState:   builtin.PaymentChannelActorCodeID,
+}
+
+// ActorCodeMismatchError is returned when CheckActorCode finds that an actor
+// is not of the type it is being transformed as.
+type ActorCodeMismatchError struct {
+	Type    LotusType
+	Address addr.Address
+	Code    cid.Cid
+}
+
+func (e *ActorCodeMismatchError) Error() string {
+	return fmt.Sprintf("actor %s is a %s actor (code %s) and cannot be transformed as %s", e.Address, builtin.ActorNameByCode(e.Code), e.Code, e.Type)
+}
+
+// ErrNotActorState is returned by CheckActorCode for a LotusType which is not
+// the head state of an actor.
+var ErrNotActorState = errors.New("type is not the head state of an actor")
+
+// checkActorCode performs the check requested by CheckActorCode on `head`.
+func (c *transformConfig) checkActorCode(ctx context.Context, head cid.Cid, store blockstore.Blockstore, as LotusType) error {
+	expected, ok := actorCodes[as]
+	if !ok {
+		return fmt.Errorf("%w: cannot check the actor code of %s", ErrNotActorState, as)
+	}
+	cborStore := cbor.NewCborStore(store)
+	node, err := hamt.LoadNode(ctx, cborStore, c.ActorRoot, hamtOptions(c.bitWidth())...)
+	if err != nil {
+		return err
+	}
+	var act lotusTypes.Actor
+	if err := node.Find(ctx, string(c.ActorAddress.Bytes()), &act); err != nil {
+		return fmt.Errorf("finding actor %s: %w", c.ActorAddress, err)
+	}
+	if !act.Head.Equals(head) {
+		return fmt.Errorf("%s is not the head of actor %s, which is %s", head, c.ActorAddress, act.Head)
+	}
+	if !act.Code.Equals(expected) {
+		return &ActorCodeMismatchError{Type: as, Address: c.ActorAddress, Code: act.Code}
+	}
+	return nil
+}
+
+// MaxMemory aborts a transform with ErrTooLarge once the blocks it has read
+// exceed `bytes` in total. The size of the decoded value grows with the data
+// read, so this bounds the memory a single transform can consume without
+// knowing the size of a collection up front. Blocks read more than once, as
+// when prefetching, are counted each time.
+func MaxMemory(bytes uint64) TransformOption {
+	return func(c *transformConfig) {
+		c.MaxMemory = bytes
+	}
+}
+
+// Lenient is a best-effort mode for state written by a different version of
+// an actor than the one decoded by this package. Where a struct fails to
+// decode because it holds more fields than expected, its leading fields are
+// decoded and the extra trailing fields ignored. Fields missing from the end
+// of a struct are not filled in, and decoding still fails.
+func Lenient(c *transformConfig) {
+	c.Lenient = true
+}
+
+// RejectTrailingData rejects blocks which hold bytes following the encoded
+// value they are decoded as, with ErrTrailingData. It does not check the
+// fields of structs: those decoded by this package are encoded as tuples of a
+// fixed length, so a struct with unexpected fields fails to decode whether or
+// not this option is set.
+func RejectTrailingData(c *transformConfig) {
+	c.RejectTrailingData = true
+}
+
+// ErrTrailingData is returned by RejectTrailingData transforms of blocks with
+// unread data.
+var ErrTrailingData = errors.New("trailing data after decoded value")
+
+// BitWidth sets the bit width of the state root, which otherwise defaults to
+// the width of 5 used by the actors, along with that of the other HAMTs keyed
+// by address which are read without the actors' adt package: the init
+// actor's address map, the power actor's claims, and the verified registry's
+// verifiers and clients. The market balance tables are always read with the
+// width of 5.
+func BitWidth(width int) TransformOption {
+	return func(c *transformConfig) {
+		c.BitWidth = width
+	}
+}
+
+// defaultBitWidth is the bit width of HAMTs written by the actors.
+const defaultBitWidth = 5
+
+func (c *transformConfig) bitWidth() int {
+	if c.BitWidth > 0 {
+		return c.BitWidth
+	}
+	return defaultBitWidth
+}
+
+// hamtOptions configures a HAMT as the actors build them, hashing keys with
+// sha256, but with the given bit width. Walking a HAMT does not depend on
+// the hash, but finding or setting a key does.
+func hamtOptions(bitwidth int) []hamt.Option {
+	return append(append([]hamt.Option{}, adt.HamtOptions...), hamt.UseTreeBitWidth(bitwidth))
+}
+
+// BlockTimeout fails a transform with a *BlockTimeoutError when any single
+// block takes longer than `d` to read, so that one slow block of a remote
+// store fails fast rather than stalling the whole transform.
+func BlockTimeout(d time.Duration) TransformOption {
+	return func(c *transformConfig) {
+		c.BlockTimeout = d
+	}
+}
+
+// ErrTooLarge is returned when a transform reads more data than allowed by MaxMemory.
+var ErrTooLarge = errors.New("transform too large")
+
+// ErrEmptyStateRoot is returned when a state root holds no actors. Roots on
+// chain always hold the singleton actors, so this usually means the root was
+// built with a different bit width; see BitWidth. Callers expecting empty
+// roots, such as those of synthetic test states, can check for it with
+// errors.Is.
+var ErrEmptyStateRoot = errors.New("state root has no actors")
+
+// ErrMaxDepth is returned when a transform nests deeper than allowed by MaxDepth.
+var ErrMaxDepth = errors.New("maximum transform depth exceeded")
+
+// enter descends a level into nested data, failing if this exceeds the
+// configured maximum depth. Each successful enter must be paired with a leave.
+func (c *transformConfig) enter() error {
+	if c.MaxDepth > 0 && c.depth >= c.MaxDepth {
+		return fmt.Errorf("%w: limit is %d", ErrMaxDepth, c.MaxDepth)
+	}
+	c.depth++
+	return nil
+}
+
+func (c *transformConfig) leave() {
+	c.depth--
+}
+
 // Transform will unmarshal cbor data based on a provided type hint.
-func Transform(ctx context.Context, c cid.Cid, store blockstore.Blockstore, as string) (interface{}, error) {
-	as = string(simplifyingRe2.ReplaceAll(simplifyingRe.ReplaceAll([]byte(as), []byte("")), []byte(".")))
+func Transform(ctx context.Context, c cid.Cid, store blockstore.Blockstore, as string, opts ...TransformOption) (interface{}, error) {
+	return TransformType(ctx, c, store, ResolveType(as), opts...)
+}
+
+// TransformType will unmarshal cbor data as an already resolved LotusType.
+// Blocks missing from the store are reported as a *BlockNotFoundError.
+func TransformType(ctx context.Context, c cid.Cid, store blockstore.Blockstore, as LotusType, opts ...TransformOption) (out interface{}, err error) {
+	conf := transformConfig{}
+	for _, o := range opts {
+		o(&conf)
+	}
+	if err := conf.enter(); err != nil {
+		return nil, err
+	}
+	defer conf.leave()
+	store = conf.wrapStore(store)
+	if conf.ActorRoot.Defined() {
+		if err := conf.checkActorCode(ctx, c, store, as); err != nil {
+			return nil, err
+		}
+	}
+	defer func() {
+		if err == nil {
+			conf.countEntries(out)
+		}
+	}()
 
 	// First select types which do their own store loading.
-	switch LotusType(as) {
-	case LotusTypeStateroot:
-		return transformStateRoot(ctx, c, store)
-	case InitActorAddresses:
-		return transformInitActor(ctx, c, store)
-	case StorageMinerActorPreCommittedSectors:
-		return transformMinerActorPreCommittedSectors(ctx, c, store)
-	case StorageMinerActorDeadlinePartitionEarly:
-		fallthrough
-	case StorageMinerActorPreCommittedSectorsExpiry:
-		return transformMinerActorPreCommittedSectorsExpiry(ctx, c, store)
-	case StorageMinerActorSectors:
-		return transformMinerActorSectors(ctx, c, store)
-	case StorageMinerActorDeadlinePartitions:
-		return transformMinerActorDeadlinePartitions(ctx, c, store)
-	case StorageMinerActorDeadlinePartitionExpiry:
-		return transformMinerActorDeadlinePartitionExpiry(ctx, c, store)
-	case StorageMinerActorDeadlineExpiry:
-		return transformMinerActorDeadlineExpiry(ctx, c, store)
-	case StoragePowerActorCronEventQueue:
-		return transformPowerActorEventQueue(ctx, c, store)
-	case StoragePowerActorClaims:
-		return transformPowerActorClaims(ctx, c, store)
-	case MarketActorProposals:
-		return transformMarketProposals(ctx, c, store)
-	case MarketActorStates:
-		return transformMarketStates(ctx, c, store)
-	case MarketActorPendingProposals:
-		return transformMarketPendingProposals(ctx, c, store)
-	case MarketActorEscrowTable:
-		fallthrough
-	case MarketActorLockedTable:
-		return transformMarketBalanceTable(ctx, c, store)
-	case MarketActorDealOpsByEpoch:
-		return transformMarketDealOpsByEpoch(ctx, c, store)
-	case MultisigActorPending:
-		return transformMultisigPending(ctx, c, store)
-	case VerifiedRegistryActorVerifiers:
-		fallthrough
-	case VerifiedRegistryActorVerifiedClients:
-		return transformVerifiedRegistryDataCaps(ctx, c, store)
-	case PaymentChannelActorLaneStates:
-		return transformPaymentChannelLaneStates(ctx, c, store)
-	default:
+	if load, ok := complexLoaders[as]; ok {
+		return load(ctx, c, store, &conf)
 	}
 
 	block, err := store.Get(c)
@@ -133,86 +412,256 @@ func Transform(ctx context.Context, c cid.Cid, store blockstore.Blockstore, as s
 	data := block.RawData()
 
 	// Then select types which use block data.
-	switch LotusType(as) {
-	case LotusTypeTipset:
-		dest := lotusTypes.BlockHeader{}
-		err := cbor.DecodeInto(data, &dest)
-		return dest, err
-	case AccountActorState:
-		dest := accountActor.State{}
-		err := cbor.DecodeInto(data, &dest)
-		return dest, err
-	case CronActorState:
-		dest := cronActor.State{}
-		err := cbor.DecodeInto(data, &dest)
-		return dest, err
-	case InitActorState:
-		dest := initActor.State{}
-		err := cbor.DecodeInto(data, &dest)
-		return dest, err
-	case MarketActorState:
-		dest := marketActor.State{}
-		err := cbor.DecodeInto(data, &dest)
-		return dest, err
-	case MultisigActorState:
-		dest := multisigActor.State{}
-		err := cbor.DecodeInto(data, &dest)
-		return dest, err
-	case StorageMinerActorState:
-		dest := storageMinerActor.State{}
-		err := cbor.DecodeInto(data, &dest)
-		return dest, err
-	case StorageMinerActorInfo:
-		dest := storageMinerActor.MinerInfo{}
-		err := cbor.DecodeInto(data, &dest)
-		return dest, err
-	case StorageMinerActorVestingFunds:
-		dest := storageMinerActor.VestingFunds{}
-		err := cbor.DecodeInto(data, &dest)
-		return dest, err
-	case StorageMinerActorAllocatedSectors:
-		dest := bitfield.BitField{}
-		err := cbor.DecodeInto(data, &dest)
-		return JSONBitField{dest}, err
-	case StorageMinerActorDeadlines:
-		dest := storageMinerActor.Deadlines{}
-		err := cbor.DecodeInto(data, &dest)
-		return dest, err
-	case StorageMinerActorDeadline:
-		dest := storageMinerActor.Deadline{}
-		err := cbor.DecodeInto(data, &dest)
-		return dest, err
-	case StoragePowerActorState:
-		dest := storagePowerActor.State{}
-		err := cbor.DecodeInto(data, &dest)
-		return dest, err
-	case RewardActorState:
-		dest := rewardActor.State{}
-		err := cbor.DecodeInto(data, &dest)
-		return dest, err
-	case VerifiedRegistryActorState:
-		dest := verifiedRegistryActor.State{}
-		err := cbor.DecodeInto(data, &dest)
-		return dest, err
-	case PaymentChannelActorState:
-		dest := paychActor.State{}
-		err := cbor.DecodeInto(data, &dest)
-		return dest, err
-	default:
-		var dest interface{}
-		err := cbor.DecodeInto(data, &dest)
-		return dest, err
-	}
-}
-
-func transformStateRoot(ctx context.Context, c cid.Cid, store blockstore.Blockstore) (interface{}, error) {
+	if t, ok := lookupType(as); ok {
+		v, err := conf.decode(data, t)
+		if err == nil && conf.Validate {
+			err = validate(ctx, v, store)
+		}
+		return v, err
+	}
+	var dest interface{}
+	err = cbor.DecodeInto(data, &dest)
+	return dest, err
+}
+
+// Load transforms the data at `c` as a LotusType into `into`, a pointer to
+// the go type produced by the transform, so that it can be decoded directly
+// into part of a larger structure. For example:
+//
+//	var info miner.MinerInfo
+//	err := Load(ctx, infoCid, store, StorageMinerActorInfo, &info)
+//
+// Into an `interface{}`, Load behaves as TransformType.
+func Load(ctx context.Context, c cid.Cid, store blockstore.Blockstore, as LotusType, into interface{}, opts ...TransformOption) error {
+	dest := reflect.ValueOf(into)
+	if dest.Kind() != reflect.Ptr || dest.IsNil() {
+		return fmt.Errorf("cannot load into non-pointer %T", into)
+	}
+	v, err := TransformType(ctx, c, store, as, opts...)
+	if err != nil {
+		return err
+	}
+	value := reflect.ValueOf(v)
+	if !value.IsValid() {
+		dest.Elem().Set(reflect.Zero(dest.Elem().Type()))
+		return nil
+	}
+	if !value.Type().AssignableTo(dest.Elem().Type()) {
+		return fmt.Errorf("cannot load %s into %s", value.Type(), dest.Elem().Type())
+	}
+	dest.Elem().Set(value)
+	return nil
+}
+
+// TransformTry transforms the data at `c` as the first of the candidate
+// LotusTypes it fits, such as the states of different versions of an actor,
+// returning the type used. A single block type fits when the data decodes as
+// it and re-encodes to the same cid, so that no fields were lost or ignored.
+// Other types fit when they transform without error.
+func TransformTry(ctx context.Context, c cid.Cid, store blockstore.Blockstore, candidates []LotusType, opts ...TransformOption) (interface{}, LotusType, error) {
+	var lastErr error
+	for _, as := range candidates {
+		v, err := TransformType(ctx, c, store, as, opts...)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if _, ok := lookupType(as); ok {
+			if err := checkReencoding(v, c); err != nil {
+				lastErr = err
+				continue
+			}
+		}
+		return v, as, nil
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no candidate types")
+	}
+	return nil, "", fmt.Errorf("%s matches none of %v: %w", c, candidates, lastErr)
+}
+
+// ProbeType guesses what the block at `c` holds, when only its cid is known,
+// by decoding it as each single block LotusType. The types it decodes as, and
+// re-encodes back to `c` from, are returned. Collections and the state root
+// span many blocks and are not considered.
+func ProbeType(ctx context.Context, c cid.Cid, store blockstore.Blockstore) ([]LotusType, error) {
+	block, err := (&classifyingBlockstore{store}).Get(c)
+	if err != nil {
+		return nil, err
+	}
+	matches := make([]LotusType, 0)
+	for _, as := range registeredTypes() {
+		t, ok := lookupType(as)
+		if !ok {
+			continue
+		}
+		v, err := decodeAs(block.RawData(), t, false)
+		if err != nil {
+			continue
+		}
+		if checkReencoding(v, c) == nil {
+			matches = append(matches, as)
+		}
+	}
+	return matches, nil
+}
+
+// checkReencoding verifies that a decoded value encodes back to cid `c`.
+func checkReencoding(v interface{}, c cid.Cid) error {
+	rv := reflect.ValueOf(v)
+	ptr := reflect.New(rv.Type())
+	ptr.Elem().Set(rv)
+	m, ok := ptr.Interface().(cbg.CBORMarshaler)
+	if !ok {
+		return fmt.Errorf("%T cannot be re-encoded", v)
+	}
+	var buf bytes.Buffer
+	if err := m.MarshalCBOR(&buf); err != nil {
+		return err
+	}
+	computed, err := c.Prefix().Sum(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	if !computed.Equals(c) {
+		return &CIDMismatchError{Expected: c, Computed: computed}
+	}
+	return nil
+}
+
+// simpleTypes are the LotusTypes held within a single block, along with the
+// go type each is decoded as.
+var simpleTypes = map[LotusType]reflect.Type{
+	LotusTypeTipset:                   reflect.TypeOf(lotusTypes.BlockHeader{}),
+	AccountActorState:                 reflect.TypeOf(accountActor.State{}),
+	CronActorState:                    reflect.TypeOf(cronActor.State{}),
+	InitActorState:                    reflect.TypeOf(initActor.State{}),
+	MarketActorState:                  reflect.TypeOf(marketActor.State{}),
+	MultisigActorState:                reflect.TypeOf(multisigActor.State{}),
+	StorageMinerActorState:            reflect.TypeOf(storageMinerActor.State{}),
+	StorageMinerActorInfo:             reflect.TypeOf(storageMinerActor.MinerInfo{}),
+	StorageMinerActorVestingFunds:     reflect.TypeOf(storageMinerActor.VestingFunds{}),
+	StorageMinerActorAllocatedSectors: reflect.TypeOf(bitfield.BitField{}),
+	StorageMinerActorDeadlines:        reflect.TypeOf(storageMinerActor.Deadlines{}),
+	StorageMinerActorDeadline:         reflect.TypeOf(storageMinerActor.Deadline{}),
+	StoragePowerActorState:            reflect.TypeOf(storagePowerActor.State{}),
+	RewardActorState:                  reflect.TypeOf(rewardActor.State{}),
+	VerifiedRegistryActorState:        reflect.TypeOf(verifiedRegistryActor.State{}),
+	PaymentChannelActorState:          reflect.TypeOf(paychActor.State{}),
+}
+
+// decode unmarshals cbor data into a new value of type `t`, falling back to
+// decoding its leading fields when the config is lenient.
+func (c *transformConfig) decode(data []byte, t reflect.Type) (interface{}, error) {
+	v, err := decodeAs(data, t, c.RejectTrailingData)
+	if err != nil && c.Lenient && !errors.Is(err, ErrTrailingData) {
+		if lv, lerr := decodeLeading(data, t); lerr == nil {
+			return lv, nil
+		}
+	}
+	return v, err
+}
+
+// decodeLeading decodes the longest prefix of the fields of a tuple encoded
+// struct which forms a valid value of type `t`.
+func decodeLeading(data []byte, t reflect.Type) (interface{}, error) {
+	r := bytes.NewReader(data)
+	maj, n, err := cbg.CborReadHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if maj != cbg.MajArray {
+		return nil, fmt.Errorf("cannot truncate non-tuple %s", t)
+	}
+	fields := make([]cbg.Deferred, n)
+	for i := range fields {
+		if err := fields[i].UnmarshalCBOR(r); err != nil {
+			return nil, err
+		}
+	}
+	for l := len(fields) - 1; l > 0; l-- {
+		var buf bytes.Buffer
+		if err := cbg.WriteMajorTypeHeader(&buf, cbg.MajArray, uint64(l)); err != nil {
+			return nil, err
+		}
+		for _, f := range fields[:l] {
+			buf.Write(f.Raw)
+		}
+		if v, err := decodeAs(buf.Bytes(), t, false); err == nil {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("no leading fields decode as %s", t)
+}
+
+// decodeAs unmarshals cbor data into a new value of type `t`. Bitfields are
+// wrapped so that they render as JSON. When `exact` is set, data left over
+// after a type with its own cbor decoding is rejected as ErrTrailingData.
+func decodeAs(data []byte, t reflect.Type, exact bool) (interface{}, error) {
+	dest := reflect.New(t)
+	r := bytes.NewReader(data)
+	if err := cbor.DecodeReader(r, dest.Interface()); err != nil {
+		return nil, err
+	}
+	if _, ok := dest.Interface().(cbg.CBORUnmarshaler); ok && exact && r.Len() > 0 {
+		return nil, fmt.Errorf("%w: %d bytes", ErrTrailingData, r.Len())
+	}
+	if bf, ok := dest.Interface().(*bitfield.BitField); ok {
+		return JSONBitField{*bf}, nil
+	}
+	return dest.Elem().Interface(), nil
+}
+
+// loader transforms a LotusType which spans many blocks.
+type loader func(ctx context.Context, c cid.Cid, store blockstore.Blockstore, conf *transformConfig) (interface{}, error)
+
+// complexLoaders are the LotusTypes which must be loaded from the store as
+// more than a single block, along with the functions which load them.
+var complexLoaders = map[LotusType]loader{
+	LotusTypeStateroot:                         transformStateRoot,
+	LotusTypeMessageReceipts:                   transformMessageReceipts,
+	LotusTypeMessages:                          transformMessages,
+	InitActorAddresses:                         transformInitActor,
+	StorageMinerActorPreCommittedSectors:       transformMinerActorPreCommittedSectors,
+	StorageMinerActorPreCommittedSectorsExpiry: transformMinerActorPreCommittedSectorsExpiry,
+	StorageMinerActorDeadlinePartitionEarly:    transformMinerActorPreCommittedSectorsExpiry,
+	StorageMinerActorSectors:                   transformMinerActorSectors,
+	StorageMinerActorDeadlinePartitions:        transformMinerActorDeadlinePartitions,
+	StorageMinerActorDeadlinePartitionExpiry:   transformMinerActorDeadlinePartitionExpiry,
+	StorageMinerActorDeadlineExpiry:            transformMinerActorDeadlineExpiry,
+	StoragePowerActorCronEventQueue:            transformPowerActorEventQueue,
+	StoragePowerActorClaims:                    transformPowerActorClaims,
+	MarketActorProposals:                       transformMarketProposals,
+	MarketActorStates:                          transformMarketStates,
+	MarketActorPendingProposals:                transformMarketPendingProposals,
+	MarketActorEscrowTable:                     transformMarketBalanceTable,
+	MarketActorLockedTable:                     transformMarketBalanceTable,
+	MarketActorDealOpsByEpoch:                  transformMarketDealOpsByEpoch,
+	MultisigActorPending:                       transformMultisigPending,
+	VerifiedRegistryActorVerifiers:             transformVerifiedRegistryDataCaps,
+	VerifiedRegistryActorVerifiedClients:       transformVerifiedRegistryDataCaps,
+	PaymentChannelActorLaneStates:              transformPaymentChannelLaneStates,
+}
+
+// IsComplex indicates if a type must be assembled from many blocks, such as
+// the entries of a HAMT or AMT, rather than decoded from the single block at
+// its cid. Callers can use this to decide whether to fetch child blocks.
+func IsComplex(as string) bool {
+	_, ok := complexLoaders[ResolveType(as)]
+	return ok
+}
+
+func transformStateRoot(ctx context.Context, c cid.Cid, store blockstore.Blockstore, conf *transformConfig) (interface{}, error) {
 	cborStore := cbor.NewCborStore(store)
-	node, err := hamt.LoadNode(ctx, cborStore, c, hamt.UseTreeBitWidth(5))
+	node, err := hamt.LoadNode(ctx, cborStore, c, hamt.UseTreeBitWidth(conf.bitWidth()))
 	if err != nil {
 		return nil, err
 	}
+	if conf.Prefetch > 0 {
+		prefetchHAMT(ctx, cborStore, node, conf.Prefetch, hamt.UseTreeBitWidth(conf.bitWidth()))
+	}
 	m := make(map[string]*lotusTypes.Actor)
-	node.ForEach(ctx, func(k string, val interface{}) error {
+	if err := node.ForEach(ctx, func(k string, val interface{}) error {
 		actor := lotusTypes.Actor{}
 		asDef, ok := val.(*cbg.Deferred)
 		if !ok {
@@ -222,22 +671,99 @@ func transformStateRoot(ctx context.Context, c cid.Cid, store blockstore.Blockst
 		if err != nil {
 			return err
 		}
-		a, _ := addr.NewFromBytes([]byte(k))
+		a, err := addr.NewFromBytes([]byte(k))
+		if err != nil {
+			return fmt.Errorf("invalid actor address key: %w", err)
+		}
 		m[a.String()] = &actor
 		return nil
-	})
+	}); err != nil {
+		return nil, err
+	}
+	// Every state root on chain holds the singleton actors, so an empty tree
+	// usually means the root was not read as it was written.
+	if len(m) == 0 {
+		return nil, fmt.Errorf("%w: %s read with bit width %d", ErrEmptyStateRoot, c, conf.bitWidth())
+	}
+	return m, nil
+}
+
+func transformMessageReceipts(ctx context.Context, c cid.Cid, store blockstore.Blockstore, conf *transformConfig) (interface{}, error) {
+	cborStore := cbor.NewCborStore(store)
+	list, err := adt.AsArray(adt.WrapStore(ctx, cborStore), c)
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[int64]lotusTypes.MessageReceipt)
+	value := lotusTypes.MessageReceipt{}
+	if err := list.ForEach(&value, func(k int64) error {
+		m[k] = value
+		return nil
+	}); err != nil {
+		return nil, err
+	}
 	return m, nil
 }
 
-func transformInitActor(ctx context.Context, c cid.Cid, store blockstore.Blockstore) (interface{}, error) {
+// BlockMessages are the messages included in a block, in order.
+type BlockMessages struct {
+	BlsMessages   []lotusTypes.Message
+	SecpkMessages []lotusTypes.SignedMessage
+}
+
+func transformMessages(ctx context.Context, c cid.Cid, store blockstore.Blockstore, conf *transformConfig) (interface{}, error) {
+	cborStore := cbor.NewCborStore(store)
+	adtStore := adt.WrapStore(ctx, cborStore)
+	meta := lotusTypes.MsgMeta{}
+	if err := cborStore.Get(ctx, c, &meta); err != nil {
+		return nil, err
+	}
+
+	// Each list is an AMT of the cids of the messages it includes.
+	out := BlockMessages{}
+	blsList, err := adt.AsArray(adtStore, meta.BlsMessages)
+	if err != nil {
+		return nil, err
+	}
+	var msgCid cbg.CborCid
+	if err := blsList.ForEach(&msgCid, func(_ int64) error {
+		msg := lotusTypes.Message{}
+		if err := cborStore.Get(ctx, cid.Cid(msgCid), &msg); err != nil {
+			return err
+		}
+		out.BlsMessages = append(out.BlsMessages, msg)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	secpkList, err := adt.AsArray(adtStore, meta.SecpkMessages)
+	if err != nil {
+		return nil, err
+	}
+	if err := secpkList.ForEach(&msgCid, func(_ int64) error {
+		msg := lotusTypes.SignedMessage{}
+		if err := cborStore.Get(ctx, cid.Cid(msgCid), &msg); err != nil {
+			return err
+		}
+		out.SecpkMessages = append(out.SecpkMessages, msg)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func transformInitActor(ctx context.Context, c cid.Cid, store blockstore.Blockstore, conf *transformConfig) (interface{}, error) {
 	cborStore := cbor.NewCborStore(store)
-	node, err := hamt.LoadNode(ctx, cborStore, c, hamt.UseTreeBitWidth(5))
+	node, err := hamt.LoadNode(ctx, cborStore, c, hamt.UseTreeBitWidth(conf.bitWidth()))
 	if err != nil {
 		return nil, err
 	}
 	m := make(map[string]uint64)
 	var actorID cbg.CborInt
-	node.ForEach(ctx, func(k string, val interface{}) error {
+	if err := node.ForEach(ctx, func(k string, val interface{}) error {
 		asDef, ok := val.(*cbg.Deferred)
 		if !ok {
 			return fmt.Errorf("unexpected non-cbg.Deferred")
@@ -246,14 +772,19 @@ func transformInitActor(ctx context.Context, c cid.Cid, store blockstore.Blockst
 		if err != nil {
 			return err
 		}
-		a, _ := addr.NewFromBytes([]byte(k))
+		a, err := addr.NewFromBytes([]byte(k))
+		if err != nil {
+			return fmt.Errorf("invalid init actor address key: %w", err)
+		}
 		m[a.String()] = uint64(actorID)
 		return nil
-	})
+	}); err != nil {
+		return nil, err
+	}
 	return m, nil
 }
 
-func transformMinerActorPreCommittedSectors(ctx context.Context, c cid.Cid, store blockstore.Blockstore) (interface{}, error) {
+func transformMinerActorPreCommittedSectors(ctx context.Context, c cid.Cid, store blockstore.Blockstore, conf *transformConfig) (interface{}, error) {
 	cborStore := cbor.NewCborStore(store)
 	table, err := adt.AsMap(adt.WrapStore(ctx, cborStore), c)
 	if err != nil {
@@ -275,17 +806,19 @@ func transformMinerActorPreCommittedSectors(ctx context.Context, c cid.Cid, stor
 	return m, nil
 }
 
-func transformMinerActorPreCommittedSectorsExpiry(ctx context.Context, c cid.Cid, store blockstore.Blockstore) (interface{}, error) {
+func transformMinerActorPreCommittedSectorsExpiry(ctx context.Context, c cid.Cid, store blockstore.Blockstore, conf *transformConfig) (interface{}, error) {
 	cborStore := cbor.NewCborStore(store)
 	list, err := adt.AsArray(adt.WrapStore(ctx, cborStore), c)
 	if err != nil {
 		return nil, err
 	}
 
-	m := make(map[int64]JSONBitField)
+	// Queues are indexed by epoch, each entry holding the sector numbers
+	// which expire at that epoch.
+	m := make(map[abi.ChainEpoch]JSONBitField)
 	value := bitfield.BitField{}
 	if err := list.ForEach(&value, func(k int64) error {
-		m[k] = JSONBitField{value}
+		m[abi.ChainEpoch(k)] = JSONBitField{value}
 		return nil
 	}); err != nil {
 		return nil, err
@@ -293,8 +826,11 @@ func transformMinerActorPreCommittedSectorsExpiry(ctx context.Context, c cid.Cid
 	return m, nil
 }
 
-func transformMinerActorSectors(ctx context.Context, c cid.Cid, store blockstore.Blockstore) (interface{}, error) {
+func transformMinerActorSectors(ctx context.Context, c cid.Cid, store blockstore.Blockstore, conf *transformConfig) (interface{}, error) {
 	cborStore := cbor.NewCborStore(store)
+	if conf.Prefetch > 0 {
+		prefetchAMT(ctx, cborStore, c, conf.Prefetch)
+	}
 	list, err := adt.AsArray(adt.WrapStore(ctx, cborStore), c)
 	if err != nil {
 		return nil, err
@@ -311,7 +847,7 @@ func transformMinerActorSectors(ctx context.Context, c cid.Cid, store blockstore
 	return m, nil
 }
 
-func transformMinerActorDeadlinePartitions(ctx context.Context, c cid.Cid, store blockstore.Blockstore) (interface{}, error) {
+func transformMinerActorDeadlinePartitions(ctx context.Context, c cid.Cid, store blockstore.Blockstore, conf *transformConfig) (interface{}, error) {
 	cborStore := cbor.NewCborStore(store)
 	list, err := adt.AsArray(adt.WrapStore(ctx, cborStore), c)
 	if err != nil {
@@ -329,17 +865,19 @@ func transformMinerActorDeadlinePartitions(ctx context.Context, c cid.Cid, store
 	return m, nil
 }
 
-func transformMinerActorDeadlinePartitionExpiry(ctx context.Context, c cid.Cid, store blockstore.Blockstore) (interface{}, error) {
+func transformMinerActorDeadlinePartitionExpiry(ctx context.Context, c cid.Cid, store blockstore.Blockstore, conf *transformConfig) (interface{}, error) {
 	cborStore := cbor.NewCborStore(store)
 	list, err := adt.AsArray(adt.WrapStore(ctx, cborStore), c)
 	if err != nil {
 		return nil, err
 	}
 
-	m := make(map[int64]storageMinerActor.ExpirationSet)
+	// The expiration queue is indexed by the (quantized) epoch at which
+	// each set of sectors expires.
+	m := make(map[abi.ChainEpoch]storageMinerActor.ExpirationSet)
 	value := storageMinerActor.ExpirationSet{}
 	if err := list.ForEach(&value, func(k int64) error {
-		m[k] = value
+		m[abi.ChainEpoch(k)] = value
 		return nil
 	}); err != nil {
 		return nil, err
@@ -347,17 +885,19 @@ func transformMinerActorDeadlinePartitionExpiry(ctx context.Context, c cid.Cid,
 	return m, nil
 }
 
-func transformMinerActorDeadlineExpiry(ctx context.Context, c cid.Cid, store blockstore.Blockstore) (interface{}, error) {
+func transformMinerActorDeadlineExpiry(ctx context.Context, c cid.Cid, store blockstore.Blockstore, conf *transformConfig) (interface{}, error) {
 	cborStore := cbor.NewCborStore(store)
 	list, err := adt.AsArray(adt.WrapStore(ctx, cborStore), c)
 	if err != nil {
 		return nil, err
 	}
 
-	m := make(map[int64]JSONBitField)
+	// Indexed by expiration epoch, each entry marks the partitions with
+	// sectors expiring at that epoch.
+	m := make(map[abi.ChainEpoch]JSONBitField)
 	value := bitfield.BitField{}
 	if err := list.ForEach(&value, func(k int64) error {
-		m[k] = JSONBitField{value}
+		m[abi.ChainEpoch(k)] = JSONBitField{value}
 		return nil
 	}); err != nil {
 		return nil, err
@@ -365,7 +905,7 @@ func transformMinerActorDeadlineExpiry(ctx context.Context, c cid.Cid, store blo
 	return m, nil
 }
 
-func transformPowerActorEventQueue(ctx context.Context, c cid.Cid, store blockstore.Blockstore) (interface{}, error) {
+func transformPowerActorEventQueue(ctx context.Context, c cid.Cid, store blockstore.Blockstore, conf *transformConfig) (interface{}, error) {
 	cborStore := cbor.NewCborStore(store)
 	node, err := adt.AsMultimap(adt.WrapStore(ctx, cborStore), c)
 	if err != nil {
@@ -374,6 +914,11 @@ func transformPowerActorEventQueue(ctx context.Context, c cid.Cid, store blockst
 	m := make(map[uint64]map[int64]storagePowerActor.CronEvent)
 	var key cbg.CborInt
 	if err := node.ForAll(func(k string, val *adt.Array) error {
+		if err := conf.enter(); err != nil {
+			return err
+		}
+		defer conf.leave()
+
 		eval := storagePowerActor.CronEvent{}
 		items := make(map[int64]storagePowerActor.CronEvent)
 		if err := val.ForEach(&eval, func(i int64) error {
@@ -391,15 +936,15 @@ func transformPowerActorEventQueue(ctx context.Context, c cid.Cid, store blockst
 	return m, nil
 }
 
-func transformPowerActorClaims(ctx context.Context, c cid.Cid, store blockstore.Blockstore) (interface{}, error) {
+func transformPowerActorClaims(ctx context.Context, c cid.Cid, store blockstore.Blockstore, conf *transformConfig) (interface{}, error) {
 	cborStore := cbor.NewCborStore(store)
-	node, err := hamt.LoadNode(ctx, cborStore, c, hamt.UseTreeBitWidth(5))
+	node, err := hamt.LoadNode(ctx, cborStore, c, hamt.UseTreeBitWidth(conf.bitWidth()))
 	if err != nil {
 		return nil, err
 	}
 	m := make(map[string]storagePowerActor.Claim)
 	var claim storagePowerActor.Claim
-	node.ForEach(ctx, func(k string, val interface{}) error {
+	if err := node.ForEach(ctx, func(k string, val interface{}) error {
 		asDef, ok := val.(*cbg.Deferred)
 		if !ok {
 			return fmt.Errorf("unexpected non-cbg.Deferred")
@@ -408,22 +953,27 @@ func transformPowerActorClaims(ctx context.Context, c cid.Cid, store blockstore.
 		if err != nil {
 			return err
 		}
-		a, _ := addr.NewFromBytes([]byte(k))
+		a, err := addr.NewFromBytes([]byte(k))
+		if err != nil {
+			return fmt.Errorf("invalid power claim address key: %w", err)
+		}
 		m[a.String()] = claim
 		return nil
-	})
+	}); err != nil {
+		return nil, err
+	}
 	return m, nil
 }
 
-func transformVerifiedRegistryDataCaps(ctx context.Context, c cid.Cid, store blockstore.Blockstore) (interface{}, error) {
+func transformVerifiedRegistryDataCaps(ctx context.Context, c cid.Cid, store blockstore.Blockstore, conf *transformConfig) (interface{}, error) {
 	cborStore := cbor.NewCborStore(store)
-	node, err := hamt.LoadNode(ctx, cborStore, c, hamt.UseTreeBitWidth(5))
+	node, err := hamt.LoadNode(ctx, cborStore, c, hamt.UseTreeBitWidth(conf.bitWidth()))
 	if err != nil {
 		return nil, err
 	}
-	m := make(map[string]verifiedRegistryActor.DataCap)
+	m := make(map[string]JSONDataCap)
 	var dataCap verifiedRegistryActor.DataCap
-	node.ForEach(ctx, func(k string, val interface{}) error {
+	if err := node.ForEach(ctx, func(k string, val interface{}) error {
 		asDef, ok := val.(*cbg.Deferred)
 		if !ok {
 			return fmt.Errorf("unexpected non-cbg.Deferred")
@@ -432,26 +982,35 @@ func transformVerifiedRegistryDataCaps(ctx context.Context, c cid.Cid, store blo
 		if err != nil {
 			return err
 		}
-		a, _ := addr.NewFromBytes([]byte(k))
-		m[a.String()] = dataCap
+		a, err := addr.NewFromBytes([]byte(k))
+		if err != nil {
+			return fmt.Errorf("invalid data cap address key: %w", err)
+		}
+		m[a.String()] = JSONDataCap{dataCap}
 		return nil
-	})
+	}); err != nil {
+		return nil, err
+	}
 	return m, nil
 }
 
-func transformMarketPendingProposals(ctx context.Context, c cid.Cid, store blockstore.Blockstore) (interface{}, error) {
+func transformMarketPendingProposals(ctx context.Context, c cid.Cid, store blockstore.Blockstore, conf *transformConfig) (interface{}, error) {
 	cborStore := cbor.NewCborStore(store)
 	mapper, err := adt.AsMap(adt.WrapStore(ctx, cborStore), c)
 	if err != nil {
 		return nil, err
 	}
 
+	// Pending proposals are keyed by the binary form of the proposal CID,
+	// which is rendered back into its string form for output.
 	m := make(map[string]marketActor.DealProposal)
-	cidr := cid.Undef
 	value := marketActor.DealProposal{}
-	if err := mapper.ForEach(&value, func(c string) error {
-		cidr.UnmarshalBinary([]byte(c))
-		m[cidr.String()] = value
+	if err := mapper.ForEach(&value, func(k string) error {
+		proposalCid, err := cid.Cast([]byte(k))
+		if err != nil {
+			return fmt.Errorf("invalid pending proposal key: %w", err)
+		}
+		m[proposalCid.String()] = value
 		return nil
 	}); err != nil {
 		return nil, err
@@ -459,7 +1018,7 @@ func transformMarketPendingProposals(ctx context.Context, c cid.Cid, store block
 	return m, nil
 }
 
-func transformMarketProposals(ctx context.Context, c cid.Cid, store blockstore.Blockstore) (interface{}, error) {
+func transformMarketProposals(ctx context.Context, c cid.Cid, store blockstore.Blockstore, conf *transformConfig) (interface{}, error) {
 	cborStore := cbor.NewCborStore(store)
 	list, err := adt.AsArray(adt.WrapStore(ctx, cborStore), c)
 	if err != nil {
@@ -477,7 +1036,7 @@ func transformMarketProposals(ctx context.Context, c cid.Cid, store blockstore.B
 	return m, nil
 }
 
-func transformMarketStates(ctx context.Context, c cid.Cid, store blockstore.Blockstore) (interface{}, error) {
+func transformMarketStates(ctx context.Context, c cid.Cid, store blockstore.Blockstore, conf *transformConfig) (interface{}, error) {
 	cborStore := cbor.NewCborStore(store)
 	list, err := adt.AsArray(adt.WrapStore(ctx, cborStore), c)
 	if err != nil {
@@ -495,7 +1054,7 @@ func transformMarketStates(ctx context.Context, c cid.Cid, store blockstore.Bloc
 	return m, nil
 }
 
-func transformMarketBalanceTable(ctx context.Context, c cid.Cid, store blockstore.Blockstore) (interface{}, error) {
+func transformMarketBalanceTable(ctx context.Context, c cid.Cid, store blockstore.Blockstore, conf *transformConfig) (interface{}, error) {
 	cborStore := cbor.NewCborStore(store)
 	table, err := adt.AsMap(adt.WrapStore(ctx, cborStore), c)
 	if err != nil {
@@ -505,7 +1064,10 @@ func transformMarketBalanceTable(ctx context.Context, c cid.Cid, store blockstor
 	m := make(map[string]abi.TokenAmount)
 	var value abi.TokenAmount
 	if err := table.ForEach(&value, func(k string) error {
-		a, _ := addr.NewFromBytes([]byte(k))
+		a, err := addr.NewFromBytes([]byte(k))
+		if err != nil {
+			return fmt.Errorf("invalid balance table address key: %w", err)
+		}
 		m[a.String()] = value
 		return nil
 	}); err != nil {
@@ -514,7 +1076,7 @@ func transformMarketBalanceTable(ctx context.Context, c cid.Cid, store blockstor
 	return m, nil
 }
 
-func transformMarketDealOpsByEpoch(ctx context.Context, c cid.Cid, store blockstore.Blockstore) (interface{}, error) {
+func transformMarketDealOpsByEpoch(ctx context.Context, c cid.Cid, store blockstore.Blockstore, conf *transformConfig) (interface{}, error) {
 	adtStore := adt.WrapStore(ctx, cbor.NewCborStore(store))
 	table, err := adt.AsMap(adtStore, c)
 	if err != nil {
@@ -525,19 +1087,26 @@ func transformMarketDealOpsByEpoch(ctx context.Context, c cid.Cid, store blockst
 	var key cbg.CborInt
 	var value cbg.CborCid
 	if err := table.ForEach(&value, func(k string) error {
+		if err := conf.enter(); err != nil {
+			return err
+		}
+		defer conf.leave()
+
 		set, err := adt.AsSet(adtStore, cid.Cid(value))
 		if err != nil {
 			return err
 		}
 		vals := make([]abi.DealID, 0)
-		set.ForEach(func(d string) error {
+		if err := set.ForEach(func(d string) error {
 			key, err := abi.ParseUIntKey(d)
 			if err != nil {
 				return err
 			}
 			vals = append(vals, abi.DealID(key))
 			return nil
-		})
+		}); err != nil {
+			return err
+		}
 
 		(&key).UnmarshalCBOR(bytes.NewBuffer([]byte(k)))
 		m[uint64(key)] = vals
@@ -548,7 +1117,7 @@ func transformMarketDealOpsByEpoch(ctx context.Context, c cid.Cid, store blockst
 	return m, nil
 }
 
-func transformMultisigPending(ctx context.Context, c cid.Cid, store blockstore.Blockstore) (interface{}, error) {
+func transformMultisigPending(ctx context.Context, c cid.Cid, store blockstore.Blockstore, conf *transformConfig) (interface{}, error) {
 	cborStore := cbor.NewCborStore(store)
 	table, err := adt.AsMap(adt.WrapStore(ctx, cborStore), c)
 	if err != nil {
@@ -565,10 +1134,22 @@ func transformMultisigPending(ctx context.Context, c cid.Cid, store blockstore.B
 	}); err != nil {
 		return nil, err
 	}
-	return m, nil
+	if conf.ParamsDecoder == nil {
+		return m, nil
+	}
+
+	decoded := make(map[int64]MultisigTransaction, len(m))
+	for id, txn := range m {
+		entry := MultisigTransaction{Transaction: txn}
+		if params, ok := conf.ParamsDecoder(txn.To, txn.Method, txn.Params); ok {
+			entry.DecodedParams = params
+		}
+		decoded[id] = entry
+	}
+	return decoded, nil
 }
 
-func transformPaymentChannelLaneStates(ctx context.Context, c cid.Cid, store blockstore.Blockstore) (interface{}, error) {
+func transformPaymentChannelLaneStates(ctx context.Context, c cid.Cid, store blockstore.Blockstore, conf *transformConfig) (interface{}, error) {
 	cborStore := cbor.NewCborStore(store)
 	list, err := adt.AsArray(adt.WrapStore(ctx, cborStore), c)
 	if err != nil {
@@ -583,5 +1164,8 @@ func transformPaymentChannelLaneStates(ctx context.Context, c cid.Cid, store blo
 	}); err != nil {
 		return nil, err
 	}
+	if conf.Validate {
+		return m, validateLaneStates(m)
+	}
 	return m, nil
 }
@@ -0,0 +1,552 @@
+package statediff_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-bitfield"
+	abi "github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	accountActor "github.com/filecoin-project/specs-actors/actors/builtin/account"
+	marketActor "github.com/filecoin-project/specs-actors/actors/builtin/market"
+	storageMinerActor "github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	storagePowerActor "github.com/filecoin-project/specs-actors/actors/builtin/power"
+	"github.com/filecoin-project/specs-actors/actors/runtime/proof"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	"github.com/filecoin-project/statediff"
+	"github.com/filecoin-project/statediff/testutil"
+)
+
+func TestCheckActorCode(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+
+	account := idAddr(t, 100)
+	head := mustPut(t, store, &accountActor.State{Address: account})
+	other := mustPut(t, store, &accountActor.State{Address: idAddr(t, 101)})
+	root := mustStateTree(t, store, map[addr.Address]*types.Actor{
+		account: {Code: builtin.AccountActorCodeID, Head: head, Balance: types.NewInt(0)},
+	})
+
+	var mismatch *statediff.ActorCodeMismatchError
+	for _, tc := range []struct {
+		name  string
+		head  cid.Cid
+		as    statediff.LotusType
+		check func(error) bool
+	}{
+		{"matching code", head, statediff.AccountActorState, func(err error) bool { return err == nil }},
+		{"other actor type", head, statediff.StorageMinerActorState, func(err error) bool { return errors.As(err, &mismatch) }},
+		{"not a head type", head, statediff.StorageMinerActorInfo, func(err error) bool { return errors.Is(err, statediff.ErrNotActorState) }},
+		{"not the actor's head", other, statediff.AccountActorState, func(err error) bool { return err != nil && !errors.As(err, &mismatch) }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := statediff.TransformType(ctx, tc.head, store, tc.as, statediff.CheckActorCode(root, account))
+			if !tc.check(err) {
+				t.Fatalf("unexpected result %v", err)
+			}
+		})
+	}
+	if mismatch == nil || !mismatch.Code.Equals(builtin.AccountActorCodeID) {
+		t.Errorf("mismatch reported code %v, want the account actor code", mismatch)
+	}
+}
+
+func TestTransformStateRootBitWidth(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+	actor := &types.Actor{Code: builtin.AccountActorCodeID, Head: mustPut(t, store, &accountActor.State{Address: idAddr(t, 100)}), Balance: types.NewInt(1)}
+	key := string(idAddr(t, 100).Bytes())
+
+	for _, tc := range []struct {
+		name     string
+		entries  map[string]cbg.CBORMarshaler
+		bitwidth int
+		opts     []statediff.TransformOption
+		actors   int
+		empty    bool
+	}{
+		{"default width", map[string]cbg.CBORMarshaler{key: actor}, 5, nil, 1, false},
+		{"genesis width", map[string]cbg.CBORMarshaler{key: actor}, 8, []statediff.TransformOption{statediff.BitWidth(8)}, 1, false},
+		{"empty root", nil, 5, nil, 0, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			root := mustHAMT(t, store, tc.entries, tc.bitwidth)
+			out, err := statediff.TransformType(ctx, root, store, statediff.LotusTypeStateroot, tc.opts...)
+			if tc.empty != errors.Is(err, statediff.ErrEmptyStateRoot) {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if tc.empty {
+				if out != nil {
+					t.Errorf("empty root transformed as %v", out)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			actors, ok := out.(map[string]*types.Actor)
+			if !ok {
+				t.Fatalf("state root transformed as %T", out)
+			}
+			if len(actors) != tc.actors {
+				t.Errorf("found %d actors, want %d", len(actors), tc.actors)
+			}
+		})
+	}
+}
+
+func TestTransformMarketPendingProposals(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+	proposal := testProposal(t, 100, 101, 10)
+	proposalCid, err := proposal.Cid()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		key     string
+		wantKey string
+	}{
+		{"cid key", string(proposalCid.Bytes()), proposalCid.String()},
+		{"invalid key", "not a cid", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			root := mustHAMT(t, store, map[string]cbg.CBORMarshaler{tc.key: proposal}, 5)
+			out, err := statediff.TransformType(ctx, root, store, statediff.MarketActorPendingProposals)
+			if tc.wantKey == "" {
+				if err == nil {
+					t.Fatal("expected an error for an invalid key")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := out.(map[string]marketActor.DealProposal)[tc.wantKey]; !ok {
+				t.Fatalf("no proposal keyed by %s in %v", tc.wantKey, out)
+			}
+			if js := mustMarshalJSON(t, out); !strings.Contains(js, `{"`+tc.wantKey+`":{`) {
+				t.Errorf("proposal not rendered under its cid: %s", js)
+			}
+		})
+	}
+}
+
+func TestTransformStateRootAddressKeys(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+	actor := &types.Actor{Code: builtin.AccountActorCodeID, Head: mustPut(t, store, &accountActor.State{Address: idAddr(t, 100)}), Balance: types.NewInt(1)}
+	robust, err := addr.NewActorAddress([]byte("robust"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		key     string
+		wantKey string
+	}{
+		{"id address", string(idAddr(t, 100).Bytes()), idAddr(t, 100).String()},
+		{"actor address", string(robust.Bytes()), robust.String()},
+		{"invalid address", "\xff", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			root := mustHAMT(t, store, map[string]cbg.CBORMarshaler{tc.key: actor}, 5)
+			out, err := statediff.TransformType(ctx, root, store, statediff.LotusTypeStateroot)
+			if tc.wantKey == "" {
+				if err == nil {
+					t.Fatal("expected an error for an invalid key")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if js := mustMarshalJSON(t, out); !strings.HasPrefix(js, `{"`+tc.wantKey+`":{`) {
+				t.Errorf("actor not rendered under %s: %s", tc.wantKey, js)
+			}
+		})
+	}
+}
+
+func TestTransformMarketBalanceTable(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+
+	for _, tc := range []struct {
+		name    string
+		entries map[string]cbg.CBORMarshaler
+		want    string
+	}{
+		{
+			"address keys and decimal balances",
+			map[string]cbg.CBORMarshaler{
+				string(idAddr(t, 100).Bytes()): tokens(5),
+				string(idAddr(t, 101).Bytes()): tokens(-7),
+			},
+			`{"t0100":"5","t0101":"-7"}`,
+		},
+		{
+			"invalid address key",
+			map[string]cbg.CBORMarshaler{"\xff": tokens(5)},
+			"",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			root := mustHAMT(t, store, tc.entries, 5)
+			for _, as := range []statediff.LotusType{statediff.MarketActorEscrowTable, statediff.MarketActorLockedTable} {
+				out, err := statediff.TransformType(ctx, root, store, as)
+				if tc.want == "" {
+					if err == nil {
+						t.Fatalf("%s: expected an error for an invalid key", as)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if js := mustMarshalJSON(t, out); js != tc.want {
+					t.Errorf("%s rendered as %s, want %s", as, js, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestTransformAddressKeyedTables(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+	id := cbg.CborInt(100)
+	claim := &storagePowerActor.Claim{RawBytePower: abi.NewStoragePower(1), QualityAdjPower: abi.NewStoragePower(2)}
+
+	for _, tc := range []struct {
+		as    statediff.LotusType
+		value cbg.CBORMarshaler
+	}{
+		{statediff.InitActorAddresses, &id},
+		{statediff.StoragePowerActorClaims, claim},
+		{statediff.VerifiedRegistryActorVerifiers, tokens(3)},
+		{statediff.VerifiedRegistryActorVerifiedClients, tokens(3)},
+	} {
+		t.Run(string(tc.as), func(t *testing.T) {
+			valid := mustHAMT(t, store, map[string]cbg.CBORMarshaler{string(idAddr(t, 100).Bytes()): tc.value}, 5)
+			out, err := statediff.TransformType(ctx, valid, store, tc.as)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if js := mustMarshalJSON(t, out); !strings.HasPrefix(js, `{"t0100":`) {
+				t.Errorf("rendered as %s", js)
+			}
+
+			invalid := mustHAMT(t, store, map[string]cbg.CBORMarshaler{"\xff": tc.value}, 5)
+			if _, err := statediff.TransformType(ctx, invalid, store, tc.as); err == nil {
+				t.Error("expected an error for an invalid key")
+			}
+		})
+	}
+}
+
+func TestTransformPartitionExpirationQueue(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+	set := func(onTime, early []uint64) *storageMinerActor.ExpirationSet {
+		return &storageMinerActor.ExpirationSet{
+			OnTimeSectors: bitfield.NewFromSet(onTime),
+			EarlySectors:  bitfield.NewFromSet(early),
+			OnTimePledge:  abi.NewTokenAmount(1),
+			ActivePower:   storageMinerActor.NewPowerPairZero(),
+			FaultyPower:   storageMinerActor.NewPowerPairZero(),
+		}
+	}
+	root := mustAMT(t, store, map[uint64]cbg.CBORMarshaler{
+		100: set([]uint64{1, 2}, nil),
+		200: set([]uint64{3}, []uint64{4, 5}),
+	})
+
+	out, err := statediff.TransformType(ctx, root, store, statediff.StorageMinerActorDeadlinePartitionExpiry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue, ok := out.(map[abi.ChainEpoch]storageMinerActor.ExpirationSet)
+	if !ok {
+		t.Fatalf("expiration queue transformed as %T", out)
+	}
+	for _, tc := range []struct {
+		epoch  abi.ChainEpoch
+		onTime []uint64
+		early  []uint64
+	}{
+		{100, []uint64{1, 2}, []uint64{}},
+		{200, []uint64{3}, []uint64{4, 5}},
+	} {
+		es, ok := queue[tc.epoch]
+		if !ok {
+			t.Fatalf("no expiration set at epoch %d", tc.epoch)
+		}
+		for _, bf := range []struct {
+			name string
+			got  bitfield.BitField
+			want []uint64
+		}{
+			{"on time", es.OnTimeSectors, tc.onTime},
+			{"early", es.EarlySectors, tc.early},
+		} {
+			got, err := bf.got.All(1 << 20)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(append([]uint64{}, got...), bf.want) {
+				t.Errorf("epoch %d %s sectors are %v, want %v", tc.epoch, bf.name, got, bf.want)
+			}
+		}
+	}
+	if len(queue) != 2 {
+		t.Errorf("queue holds %d epochs, want 2", len(queue))
+	}
+}
+
+func TestTransformPreCommitExpiryQueue(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+	sectors := func(s ...uint64) *bitfield.BitField {
+		bf := bitfield.NewFromSet(s)
+		return &bf
+	}
+	root := mustAMT(t, store, map[uint64]cbg.CBORMarshaler{
+		100: sectors(1, 2),
+		250: sectors(7),
+	})
+
+	for _, tc := range []struct {
+		name string
+		opts []statediff.JSONOption
+		want string
+	}{
+		{"expanded", []statediff.JSONOption{statediff.ExpandBitfields}, `{"100":[1,2],"250":[7]}`},
+		{"compacted", []statediff.JSONOption{statediff.ExpandBitfields, statediff.CompactRanges}, `{"100":[[1,2]],"250":[[7,7]]}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := statediff.TransformType(ctx, root, store, statediff.StorageMinerActorPreCommittedSectorsExpiry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := out.(map[abi.ChainEpoch]statediff.JSONBitField); !ok {
+				t.Fatalf("expiry queue transformed as %T", out)
+			}
+			if js := mustMarshalJSON(t, out, tc.opts...); js != tc.want {
+				t.Errorf("rendered as %s, want %s", js, tc.want)
+			}
+		})
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+	events := mustAMT(t, store, map[uint64]cbg.CBORMarshaler{
+		0: &storagePowerActor.CronEvent{MinerAddr: idAddr(t, 1000)},
+	})
+	queueEvents := cbg.CborCid(events)
+	queue := mustHAMT(t, store, map[string]cbg.CBORMarshaler{
+		abi.IntKey(100).Key(): &queueEvents,
+	}, 5)
+	partitions := bitfield.NewFromSet([]uint64{0})
+	flat := mustAMT(t, store, map[uint64]cbg.CBORMarshaler{100: &partitions})
+
+	for _, tc := range []struct {
+		name     string
+		root     cid.Cid
+		as       statediff.LotusType
+		maxDepth int
+		exceeded bool
+	}{
+		{"flat within 1", flat, statediff.StorageMinerActorDeadlineExpiry, 1, false},
+		{"multimap within 2", queue, statediff.StoragePowerActorCronEventQueue, 2, false},
+		{"multimap exceeds 1", queue, statediff.StoragePowerActorCronEventQueue, 1, true},
+		{"unlimited", queue, statediff.StoragePowerActorCronEventQueue, 0, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := statediff.TransformType(ctx, tc.root, store, tc.as, statediff.MaxDepth(tc.maxDepth))
+			if tc.exceeded != errors.Is(err, statediff.ErrMaxDepth) {
+				t.Fatalf("MaxDepth(%d) returned %v", tc.maxDepth, err)
+			}
+			if !tc.exceeded && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestRejectTrailingData(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	if err := (&accountActor.State{Address: idAddr(t, 100)}).MarshalCBOR(&buf); err != nil {
+		t.Fatal(err)
+	}
+	buf.WriteString("extra")
+	blk := blocks.NewBlock(buf.Bytes())
+	store := testutil.NewMemStore(blk)
+
+	for _, tc := range []struct {
+		name     string
+		opts     []statediff.TransformOption
+		rejected bool
+	}{
+		{"default", nil, false},
+		{"rejected", []statediff.TransformOption{statediff.RejectTrailingData}, true},
+		{"rejected when lenient", []statediff.TransformOption{statediff.RejectTrailingData, statediff.Lenient}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := statediff.TransformType(ctx, blk.Cid(), store, statediff.AccountActorState, tc.opts...)
+			if tc.rejected {
+				if !errors.Is(err, statediff.ErrTrailingData) {
+					t.Fatalf("expected ErrTrailingData, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if state, ok := out.(accountActor.State); !ok || state.Address != idAddr(t, 100) {
+				t.Errorf("decoded as %#v", out)
+			}
+		})
+	}
+}
+
+func TestTransformTipsetWinPoStProof(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+	emptyArray := mustAMT(t, store, nil)
+	proofs := []proof.PoStProof{
+		{PoStProof: abi.RegisteredPoStProof_StackedDrgWinning2KiBV1, ProofBytes: []byte("first proof")},
+		{PoStProof: abi.RegisteredPoStProof_StackedDrgWinning32GiBV1, ProofBytes: []byte("second proof")},
+	}
+	root := mustPut(t, store, &types.BlockHeader{
+		Miner:                 idAddr(t, 1000),
+		WinPoStProof:          proofs,
+		ParentWeight:          types.NewInt(1),
+		Height:                10,
+		ParentStateRoot:       emptyArray,
+		ParentMessageReceipts: emptyArray,
+		Messages:              emptyArray,
+	})
+
+	v, err := statediff.TransformType(ctx, root, store, statediff.LotusTypeTipset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, ok := v.(types.BlockHeader)
+	if !ok {
+		t.Fatalf("tipset transformed as %T", v)
+	}
+	if !reflect.DeepEqual(header.WinPoStProof, proofs) {
+		t.Errorf("WinPoStProof decoded as %+v, want %+v", header.WinPoStProof, proofs)
+	}
+
+	var rendered struct {
+		WinPoStProof []struct {
+			PoStProof  int64
+			ProofBytes []byte
+		}
+	}
+	if err := json.Unmarshal([]byte(mustMarshalJSON(t, v)), &rendered); err != nil {
+		t.Fatal(err)
+	}
+	if len(rendered.WinPoStProof) != len(proofs) {
+		t.Fatalf("%d proofs rendered, want %d", len(rendered.WinPoStProof), len(proofs))
+	}
+	for i, p := range rendered.WinPoStProof {
+		if p.PoStProof != int64(proofs[i].PoStProof) || !bytes.Equal(p.ProofBytes, proofs[i].ProofBytes) {
+			t.Errorf("proof %d rendered as %+v, want %+v", i, p, proofs[i])
+		}
+	}
+}
+
+func TestLenient(t *testing.T) {
+	ctx := context.Background()
+	tuple := func(fields ...cbg.CBORMarshaler) blocks.Block {
+		var buf bytes.Buffer
+		if err := cbg.WriteMajorTypeHeader(&buf, cbg.MajArray, uint64(len(fields))); err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range fields {
+			if err := f.MarshalCBOR(&buf); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return blocks.NewBlock(buf.Bytes())
+	}
+	address := idAddr(t, 100)
+	extra := cbg.CborInt(7)
+	longer := tuple(&address, &extra)
+	shorter := tuple()
+	store := testutil.NewMemStore(longer, shorter)
+
+	if _, err := statediff.TransformType(ctx, longer.Cid(), store, statediff.AccountActorState); err == nil {
+		t.Error("decoded a state with an extra field without Lenient")
+	}
+	out, err := statediff.TransformType(ctx, longer.Cid(), store, statediff.AccountActorState, statediff.Lenient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state, ok := out.(accountActor.State); !ok || state.Address != address {
+		t.Errorf("decoded as %#v", out)
+	}
+	if _, err := statediff.TransformType(ctx, shorter.Cid(), store, statediff.AccountActorState, statediff.Lenient); err == nil {
+		t.Error("decoded a state missing its fields")
+	}
+}
+
+func TestTransformTry(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+	head := mustPut(t, store, &accountActor.State{Address: idAddr(t, 100)})
+	proposals := mustAMT(t, store, map[uint64]cbg.CBORMarshaler{0: testProposal(t, 100, 1000, 10)})
+
+	out, as, err := statediff.TransformTry(ctx, head, store, []statediff.LotusType{statediff.StorageMinerActorInfo, statediff.AccountActorState})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state, ok := out.(accountActor.State); as != statediff.AccountActorState || !ok || state.Address != idAddr(t, 100) {
+		t.Errorf("transformed as %s: %#v", as, out)
+	}
+
+	if _, as, err := statediff.TransformTry(ctx, proposals, store, []statediff.LotusType{statediff.AccountActorState, statediff.MarketActorProposals}); err != nil || as != statediff.MarketActorProposals {
+		t.Errorf("AMT transformed as %q: %v", as, err)
+	}
+
+	// Decoding leniently drops the extra field, so the state no longer
+	// re-encodes to the cid it was read from.
+	var buf bytes.Buffer
+	if err := cbg.WriteMajorTypeHeader(&buf, cbg.MajArray, 2); err != nil {
+		t.Fatal(err)
+	}
+	address, extra := idAddr(t, 100), cbg.CborInt(7)
+	if err := address.MarshalCBOR(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := extra.MarshalCBOR(&buf); err != nil {
+		t.Fatal(err)
+	}
+	longer := blocks.NewBlock(buf.Bytes())
+	if err := store.Put(longer); err != nil {
+		t.Fatal(err)
+	}
+	if _, as, err := statediff.TransformTry(ctx, longer.Cid(), store, []statediff.LotusType{statediff.AccountActorState}, statediff.Lenient); err == nil {
+		t.Errorf("lossy decoding accepted as %s", as)
+	}
+}
@@ -0,0 +1,111 @@
+package statediff
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-ipfs-blockstore"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	initActor "github.com/filecoin-project/specs-actors/actors/builtin/init"
+	paychActor "github.com/filecoin-project/specs-actors/actors/builtin/paych"
+	adt "github.com/filecoin-project/specs-actors/actors/util/adt"
+)
+
+// validate checks the consistency of a decoded value, for the types which
+// have invariants to check.
+func validate(ctx context.Context, v interface{}, store blockstore.Blockstore) error {
+	switch state := v.(type) {
+	case initActor.State:
+		return validateInitActor(ctx, &state, store)
+	default:
+		return nil
+	}
+}
+
+// InitActorValidationError lists the addresses of the init actor's address
+// map which are assigned IDs that have not yet been allocated.
+type InitActorValidationError struct {
+	NextID     uint64
+	Violations map[string]uint64
+}
+
+func (e *InitActorValidationError) Error() string {
+	keys := make([]string, 0, len(e.Violations))
+	for k, id := range e.Violations {
+		keys = append(keys, fmt.Sprintf("%s=%d", k, id))
+	}
+	sort.Strings(keys)
+	return fmt.Sprintf("init actor assigns IDs at or above NextID %d: %s", e.NextID, strings.Join(keys, ", "))
+}
+
+func validateInitActor(ctx context.Context, state *initActor.State, store blockstore.Blockstore) error {
+	table, err := adt.AsMap(adt.WrapStore(ctx, cbor.NewCborStore(store)), state.AddressMap)
+	if err != nil {
+		return err
+	}
+
+	violations := make(map[string]uint64)
+	var actorID cbg.CborInt
+	if err := table.ForEach(&actorID, func(k string) error {
+		if uint64(actorID) < uint64(state.NextID) {
+			return nil
+		}
+		a, err := addr.NewFromBytes([]byte(k))
+		if err != nil {
+			return fmt.Errorf("invalid init actor address key: %w", err)
+		}
+		violations[a.String()] = uint64(actorID)
+		return nil
+	}); err != nil {
+		return err
+	}
+	if len(violations) > 0 {
+		return &InitActorValidationError{uint64(state.NextID), violations}
+	}
+	return nil
+}
+
+// LaneStateValidationError lists the payment channel lanes with suspicious
+// state, along with the reason each is suspect.
+type LaneStateValidationError struct {
+	Lanes map[int64]string
+}
+
+func (e *LaneStateValidationError) Error() string {
+	lanes := make([]int64, 0, len(e.Lanes))
+	for l := range e.Lanes {
+		lanes = append(lanes, l)
+	}
+	sort.Slice(lanes, func(i, j int) bool { return lanes[i] < lanes[j] })
+	reasons := make([]string, len(lanes))
+	for i, l := range lanes {
+		reasons[i] = fmt.Sprintf("lane %d: %s", l, e.Lanes[l])
+	}
+	return fmt.Sprintf("invalid payment channel lanes: %s", strings.Join(reasons, ", "))
+}
+
+// validateLaneStates checks that each lane has redeemed a non-negative amount,
+// and that any redemption came with a voucher nonce, which must exceed the
+// initial nonce of 0.
+func validateLaneStates(lanes map[int64]paychActor.LaneState) error {
+	violations := make(map[int64]string)
+	for l, state := range lanes {
+		switch {
+		case state.Redeemed.Nil():
+			violations[l] = "missing redeemed amount"
+		case state.Redeemed.Sign() < 0:
+			violations[l] = fmt.Sprintf("negative redeemed amount %s", state.Redeemed)
+		case state.Redeemed.Sign() > 0 && state.Nonce == 0:
+			violations[l] = fmt.Sprintf("redeemed %s without a voucher nonce", state.Redeemed)
+		}
+	}
+	if len(violations) > 0 {
+		return &LaneStateValidationError{violations}
+	}
+	return nil
+}
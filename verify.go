@@ -0,0 +1,41 @@
+package statediff
+
+import (
+	"fmt"
+
+	abi "github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-ipfs-blockstore"
+)
+
+// CIDMismatchError reports a block whose contents do not hash to its cid.
+type CIDMismatchError struct {
+	Expected cid.Cid
+	Computed cid.Cid
+}
+
+func (e *CIDMismatchError) Error() string {
+	return fmt.Sprintf("block %s hashes to %s", e.Expected, e.Computed)
+}
+
+// VerifyCID checks that the block stored at `c` hashes back to `c`, computing
+// its cid with `builder`. A nil builder uses the filecoin default of dag-cbor
+// with blake2b-256; pass `c.Prefix()` to verify with the format of `c` itself,
+// or a `cid.Prefix` for objects with a different codec or multihash.
+func VerifyCID(c cid.Cid, store blockstore.Blockstore, builder cid.Builder) error {
+	if builder == nil {
+		builder = abi.CidBuilder
+	}
+	block, err := (&classifyingBlockstore{store}).Get(c)
+	if err != nil {
+		return err
+	}
+	computed, err := builder.Sum(block.RawData())
+	if err != nil {
+		return err
+	}
+	if !computed.Equals(c) {
+		return &CIDMismatchError{Expected: c, Computed: computed}
+	}
+	return nil
+}
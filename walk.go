@@ -0,0 +1,63 @@
+package statediff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-ipfs-blockstore"
+
+	lotusTypes "github.com/filecoin-project/lotus/chain/types"
+)
+
+// ActorEntry is an actor found in a state root, along with its head state.
+type ActorEntry struct {
+	Address addr.Address
+	Actor   lotusTypes.Actor
+	State   interface{}
+}
+
+// WalkStateByType transforms the state root at `c`, grouping its actors by
+// the LotusType of their head state, as determined by their code. Within each
+// group actors are ordered by ID, as DiffParallel orders them. Actors with unrecognized code are
+// grouped under the empty LotusType, with their state left undecoded.
+func WalkStateByType(ctx context.Context, c cid.Cid, store blockstore.Blockstore, opts ...TransformOption) (map[LotusType][]ActorEntry, error) {
+	root, err := TransformType(ctx, c, store, LotusTypeStateroot, opts...)
+	if errors.Is(err, ErrEmptyStateRoot) {
+		root, err = map[string]*lotusTypes.Actor{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	actors := root.(map[string]*lotusTypes.Actor)
+
+	codeTypes := make(map[cid.Cid]LotusType, len(actorCodes))
+	for t, code := range actorCodes {
+		codeTypes[code] = t
+	}
+
+	entries := make([]ActorEntry, 0, len(actors))
+	for k, act := range actors {
+		a, err := addr.NewFromString(k)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, ActorEntry{Address: a, Actor: *act})
+	}
+	sort.Slice(entries, func(i, j int) bool { return actorLess(entries[i].Address, entries[j].Address) })
+
+	groups := make(map[LotusType][]ActorEntry)
+	for _, entry := range entries {
+		t, ok := codeTypes[entry.Actor.Code]
+		if ok {
+			if entry.State, err = TransformType(ctx, entry.Actor.Head, store, t, opts...); err != nil {
+				return nil, fmt.Errorf("actor %s: %w", entry.Address, err)
+			}
+		}
+		groups[t] = append(groups[t], entry)
+	}
+	return groups, nil
+}
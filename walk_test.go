@@ -0,0 +1,71 @@
+package statediff_test
+
+import (
+	"context"
+	"testing"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	accountActor "github.com/filecoin-project/specs-actors/actors/builtin/account"
+	initActor "github.com/filecoin-project/specs-actors/actors/builtin/init"
+
+	"github.com/filecoin-project/statediff"
+	"github.com/filecoin-project/statediff/testutil"
+)
+
+func TestWalkStateByType(t *testing.T) {
+	ctx := context.Background()
+	store := testutil.NewMemStore()
+
+	initHead := mustPut(t, store, &initActor.State{
+		AddressMap:  mustHAMT(t, store, nil, 5),
+		NextID:      1001,
+		NetworkName: "test",
+	})
+	account := func(id uint64) *types.Actor {
+		head := mustPut(t, store, &accountActor.State{Address: idAddr(t, id)})
+		return &types.Actor{Code: builtin.AccountActorCodeID, Head: head, Balance: types.NewInt(id)}
+	}
+	unknown := mustPut(t, store, &accountActor.State{Address: idAddr(t, 0)})
+	root := mustStateTree(t, store, map[addr.Address]*types.Actor{
+		builtin.InitActorAddr: {Code: builtin.InitActorCodeID, Head: initHead, Balance: types.NewInt(0)},
+		idAddr(t, 1000):       account(1000),
+		idAddr(t, 999):        account(999),
+		idAddr(t, 500):        {Code: unknown, Head: unknown, Balance: types.NewInt(0)},
+	})
+
+	groups, err := statediff.WalkStateByType(ctx, root, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 3 {
+		t.Errorf("expected 3 groups, got %v", groups)
+	}
+
+	inits := groups[statediff.InitActorState]
+	if len(inits) != 1 || inits[0].Address != builtin.InitActorAddr {
+		t.Fatalf("init actors grouped as %+v", inits)
+	}
+	if state, ok := inits[0].State.(initActor.State); !ok || state.NextID != 1001 {
+		t.Errorf("init actor state decoded as %#v", inits[0].State)
+	}
+
+	accounts := groups[statediff.AccountActorState]
+	if len(accounts) != 2 {
+		t.Fatalf("accounts grouped as %+v", accounts)
+	}
+	for i, id := range []uint64{999, 1000} {
+		if accounts[i].Address != idAddr(t, id) {
+			t.Errorf("account %d is %s, want t0%d", i, accounts[i].Address, id)
+		}
+		if state, ok := accounts[i].State.(accountActor.State); !ok || state.Address != idAddr(t, id) {
+			t.Errorf("account %d state decoded as %#v", i, accounts[i].State)
+		}
+	}
+
+	unrecognized := groups[""]
+	if len(unrecognized) != 1 || unrecognized[0].Address != idAddr(t, 500) || unrecognized[0].State != nil {
+		t.Errorf("unrecognized actors grouped as %+v", unrecognized)
+	}
+}